@@ -0,0 +1,300 @@
+// Command polka-sim stands in for Polka when testing Chirpy's billing
+// webhooks locally. It creates a throwaway user, sends it a scenario's events
+// signed like Polka would, and checks the resulting state through the API.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/polka"
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+type simulator struct {
+	baseURL    string
+	apiKey     string
+	secret     string
+	maxRetries int
+	client     *http.Client
+	rnd        *rand.Rand
+}
+
+type delivery struct {
+	event scenarioEvent
+	body  []byte
+}
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "Chirpy server to send webhooks to")
+	apiKey := flag.String("api-key", os.Getenv("POLKA_KEY"), "Polka API key (defaults to $POLKA_KEY)")
+	secret := flag.String("secret", os.Getenv("POLKA_WEBHOOK_SECRET"), "webhook signing secret (defaults to $POLKA_WEBHOOK_SECRET)")
+	scenarioName := flag.String("scenario", "all", "built-in scenario name, path to a JSON scenario, or \"all\"")
+	shuffle := flag.Bool("shuffle", false, "deliver events in random order even if the scenario doesn't ask for it")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed for out-of-order delivery")
+	maxRetries := flag.Int("max-retries", 3, "retries for deliveries answered with a 5xx status")
+	flag.Parse()
+
+	sim := &simulator{
+		baseURL:    strings.TrimSuffix(*baseURL, "/"),
+		apiKey:     *apiKey,
+		secret:     *secret,
+		maxRetries: *maxRetries,
+		client:     &http.Client{Timeout: time.Second * 10},
+		rnd:        rand.New(rand.NewSource(*seed)),
+	}
+	log.Printf("seed %d\n", *seed)
+
+	var scenarios []scenario
+	if *scenarioName == "all" {
+		names := []string{}
+		for name := range builtinScenarios {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			scenarios = append(scenarios, builtinScenarios[name])
+		}
+	} else {
+		sc, err := loadScenario(*scenarioName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		scenarios = append(scenarios, sc)
+	}
+
+	failed := 0
+	for _, sc := range scenarios {
+		if *shuffle {
+			sc.Shuffle = true
+		}
+		err := sim.run(sc)
+		if err != nil {
+			failed++
+			log.Printf("FAIL %s: %v\n", sc.Name, err)
+			continue
+		}
+		log.Printf("PASS %s\n", sc.Name)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func (s *simulator) run(sc scenario) error {
+	email := fmt.Sprintf("polka-sim-%s@example.com", uuid.NewString())
+	password := uuid.NewString()
+
+	user, err := s.createUser(email, password)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := buildDeliveries(sc, user.ID, time.Now(), s.rnd)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		err = s.send(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.assert(sc.Expect, user.Handle, email, password)
+}
+
+// buildDeliveries expands a scenario into the requests Polka would make,
+// including repeated deliveries, optionally shuffled.
+func buildDeliveries(sc scenario, userId string, start time.Time, rnd *rand.Rand) ([]delivery, error) {
+	offset := func(value string) (*time.Time, error) {
+		if value == "" {
+			return nil, nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		t := start.Add(d)
+		return &t, nil
+	}
+
+	// event ids are per run so repeated simulations don't collide with
+	// events the server already recorded
+	runId := uuid.NewString()[:8]
+
+	var deliveries []delivery
+	for _, ev := range sc.Events {
+		periodStart, err := offset(ev.PeriodStartOffset)
+		if err != nil {
+			return nil, err
+		}
+		periodEnd, err := offset(ev.PeriodEndOffset)
+		if err != nil {
+			return nil, err
+		}
+		cancelAt, err := offset(ev.CancelAtOffset)
+		if err != nil {
+			return nil, err
+		}
+
+		payload := map[string]any{
+			"id":    fmt.Sprintf("%s_%s", ev.ID, runId),
+			"event": ev.Event,
+			"data": map[string]any{
+				"user_id":      userId,
+				"plan":         ev.Plan,
+				"period_start": periodStart,
+				"period_end":   periodEnd,
+				"cancel_at":    cancelAt,
+			},
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		count := ev.Deliveries
+		if count < 1 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			deliveries = append(deliveries, delivery{event: ev, body: body})
+		}
+	}
+
+	if sc.Shuffle {
+		rnd.Shuffle(len(deliveries), func(i, j int) {
+			deliveries[i], deliveries[j] = deliveries[j], deliveries[i]
+		})
+	}
+	return deliveries, nil
+}
+
+func (s *simulator) send(d delivery) error {
+	backoff := time.Millisecond * 200
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, s.baseURL+"/api/polka/webhooks", bytes.NewReader(d.body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "ApiKey "+s.apiKey)
+		if s.secret != "" {
+			req.Header.Set(polka.SignatureHeader, webhooks.Sign(s.secret, time.Now(), d.body))
+		}
+
+		res, err := s.client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			log.Printf("  %s -> %d\n", d.event.Event, res.StatusCode)
+			if res.StatusCode < 500 {
+				if res.StatusCode >= 300 {
+					return fmt.Errorf("%s delivery rejected with status %d", d.event.Event, res.StatusCode)
+				}
+				return nil
+			}
+		}
+
+		if attempt >= s.maxRetries {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("%s delivery failed with status %d", d.event.Event, res.StatusCode)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+type simUser struct {
+	ID     string `json:"id"`
+	Handle string `json:"handle"`
+	Token  string `json:"token"`
+}
+
+func (s *simulator) createUser(email, password string) (simUser, error) {
+	var user simUser
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	err := s.doJSON(http.MethodPost, "/api/users", "", body, http.StatusCreated, &user)
+	return user, err
+}
+
+func (s *simulator) assert(expect scenarioExpect, handle, email, password string) error {
+	var profile struct {
+		IsChirpyRed bool `json:"is_chirpy_red"`
+	}
+	err := s.doJSON(http.MethodGet, "/api/users/"+handle, "", nil, http.StatusOK, &profile)
+	if err != nil {
+		return err
+	}
+	if profile.IsChirpyRed != expect.IsChirpyRed {
+		return fmt.Errorf("expected is_chirpy_red to be %v, got %v", expect.IsChirpyRed, profile.IsChirpyRed)
+	}
+
+	if expect.SubscriptionStatus == "" {
+		return nil
+	}
+
+	var login simUser
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	err = s.doJSON(http.MethodPost, "/api/login", "", body, http.StatusOK, &login)
+	if err != nil {
+		return err
+	}
+
+	var subs []struct {
+		Status string `json:"status"`
+	}
+	err = s.doJSON(http.MethodGet, "/api/users/me/subscriptions", login.Token, nil, http.StatusOK, &subs)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return fmt.Errorf("expected subscription status %q, got no subscriptions", expect.SubscriptionStatus)
+	}
+	if subs[0].Status != expect.SubscriptionStatus {
+		return fmt.Errorf("expected subscription status %q, got %q", expect.SubscriptionStatus, subs[0].Status)
+	}
+	return nil
+}
+
+func (s *simulator) doJSON(method, path, token string, body []byte, wantStatus int, out any) error {
+	req, err := http.NewRequest(method, s.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != wantStatus {
+		return fmt.Errorf("%s %s: expected status %d, got %d: %s", method, path, wantStatus, res.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/polka"
+)
+
+func TestBuildDeliveriesRepeatsEvents(t *testing.T) {
+	sc := builtinScenarios["upgrade"]
+	deliveries, err := buildDeliveries(sc, "user123", time.Now(), rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(deliveries) != 3 {
+		t.Fatalf("expected 3 deliveries, got %d", len(deliveries))
+	}
+
+	var payload struct {
+		ID   string `json:"id"`
+		Data struct {
+			UserId string `json:"user_id"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(deliveries[0].body, &payload)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if payload.Data.UserId != "user123" {
+		t.Fatalf("expected user_id to be %v, got %v", "user123", payload.Data.UserId)
+	}
+	if string(deliveries[0].body) != string(deliveries[2].body) {
+		t.Fatalf("expected repeated deliveries to share the same payload")
+	}
+}
+
+func TestSendSignsAndRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		err := polka.VerifySignature("secret", r.Header.Get(polka.SignatureHeader), body, time.Now(), time.Minute)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sim := &simulator{
+		baseURL:    server.URL,
+		apiKey:     "key",
+		secret:     "secret",
+		maxRetries: 2,
+		client:     server.Client(),
+	}
+
+	err := sim.send(delivery{event: scenarioEvent{Event: "user.upgraded"}, body: []byte(`{}`)})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+}
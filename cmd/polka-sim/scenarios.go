@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// scenarioEvent is one webhook Polka would send. Period offsets are relative
+// to when the simulation starts, e.g. "720h".
+type scenarioEvent struct {
+	ID                string `json:"id"`
+	Event             string `json:"event"`
+	Plan              string `json:"plan,omitempty"`
+	PeriodStartOffset string `json:"period_start_offset,omitempty"`
+	PeriodEndOffset   string `json:"period_end_offset,omitempty"`
+	CancelAtOffset    string `json:"cancel_at_offset,omitempty"`
+	// Deliveries is how many times the event is sent, simulating Polka
+	// retrying a delivery it didn't see acknowledged.
+	Deliveries int `json:"deliveries,omitempty"`
+}
+
+type scenarioExpect struct {
+	IsChirpyRed        bool   `json:"is_chirpy_red"`
+	SubscriptionStatus string `json:"subscription_status,omitempty"`
+}
+
+type scenario struct {
+	Name    string          `json:"name"`
+	Events  []scenarioEvent `json:"events"`
+	Shuffle bool            `json:"shuffle"`
+	Expect  scenarioExpect  `json:"expect"`
+}
+
+var builtinScenarios = map[string]scenario{
+	"upgrade": {
+		Name: "upgrade",
+		Events: []scenarioEvent{
+			{ID: "evt_upgrade", Event: "user.upgraded", PeriodEndOffset: "720h", Deliveries: 3},
+		},
+		Expect: scenarioExpect{IsChirpyRed: true, SubscriptionStatus: "active"},
+	},
+	"downgrade": {
+		Name: "downgrade",
+		Events: []scenarioEvent{
+			{ID: "evt_upgrade", Event: "user.upgraded", PeriodEndOffset: "720h"},
+			{ID: "evt_downgrade", Event: "user.downgraded", Deliveries: 2},
+		},
+		Expect: scenarioExpect{IsChirpyRed: false, SubscriptionStatus: "canceled"},
+	},
+	"renewals-out-of-order": {
+		Name: "renewals-out-of-order",
+		Events: []scenarioEvent{
+			{ID: "evt_upgrade", Event: "user.upgraded", PeriodEndOffset: "720h"},
+			{ID: "evt_renew_1", Event: "user.renewed", PeriodStartOffset: "720h", PeriodEndOffset: "1440h"},
+			{ID: "evt_renew_2", Event: "user.renewed", PeriodStartOffset: "1440h", PeriodEndOffset: "2160h", Deliveries: 2},
+		},
+		Shuffle: true,
+		Expect:  scenarioExpect{IsChirpyRed: true, SubscriptionStatus: "active"},
+	},
+	"payment-failed": {
+		Name: "payment-failed",
+		Events: []scenarioEvent{
+			{ID: "evt_upgrade", Event: "user.upgraded", PeriodEndOffset: "720h"},
+			{ID: "evt_failed", Event: "user.payment_failed", PeriodEndOffset: "720h"},
+		},
+		Expect: scenarioExpect{IsChirpyRed: true, SubscriptionStatus: "past_due"},
+	},
+	"scheduled-cancel": {
+		Name: "scheduled-cancel",
+		Events: []scenarioEvent{
+			{ID: "evt_upgrade", Event: "user.upgraded", PeriodEndOffset: "720h"},
+			{ID: "evt_downgrade", Event: "user.downgraded", CancelAtOffset: "720h"},
+		},
+		Expect: scenarioExpect{IsChirpyRed: true, SubscriptionStatus: "active"},
+	},
+}
+
+// loadScenario resolves name as a built-in scenario first, then as a path
+// to a JSON scenario file.
+func loadScenario(name string) (scenario, error) {
+	if sc, ok := builtinScenarios[name]; ok {
+		return sc, nil
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return scenario{}, fmt.Errorf("unknown scenario %q: %w", name, err)
+	}
+	var sc scenario
+	err = json.Unmarshal(data, &sc)
+	if err != nil {
+		return scenario{}, fmt.Errorf("invalid scenario file %s: %w", name, err)
+	}
+	if sc.Name == "" {
+		sc.Name = name
+	}
+	return sc, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gaba-bouliva/Chirpy/internal/config"
+	"github.com/gaba-bouliva/Chirpy/internal/migrations"
+	"github.com/gaba-bouliva/Chirpy/internal/sqlite"
+	"github.com/pressly/goose/v3"
+)
+
+// runCommand runs the subcommand in args and returns the exit status.
+// loadErr is the error from loading cfg.
+func runCommand(cfg config.Config, loadErr error, args []string) int {
+	var err error
+	switch {
+	case args[0] == "migrate":
+		err = loadErr
+		if err == nil {
+			err = runMigrate(cfg.DatabaseURL, args[1:])
+		}
+	case args[0] == "config" && len(args) == 2 && args[1] == "check":
+		err = runConfigCheck(cfg, loadErr)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: chirpy [migrate <command> | config check]")
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func isSQLiteURL(dbURL string) bool {
+	return strings.HasPrefix(dbURL, "sqlite://") || strings.HasPrefix(dbURL, "file:")
+}
+
+// runMigrate implements `chirpy migrate up|down|status|redo` against the
+// database named by dbURL.
+func runMigrate(dbURL string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: chirpy migrate <command>, where command is %s", migrations.Commands)
+	}
+	if dbURL == "" || dbURL == "memory://" {
+		return errors.New("DB_URL must name a PostgreSQL or SQLite database to migrate")
+	}
+
+	var (
+		db       *sql.DB
+		provider *goose.Provider
+		err      error
+	)
+	if isSQLiteURL(dbURL) {
+		db, err = sqlite.OpenDB(dbURL)
+		if err == nil {
+			defer db.Close()
+			provider, err = migrations.SQLite(db)
+		}
+	} else {
+		db, err = sql.Open("postgres", dbURL)
+		if err == nil {
+			defer db.Close()
+			provider, err = migrations.Postgres(db)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return migrations.Run(context.Background(), provider, args[0], os.Stdout)
+}
+
+// runConfigCheck implements `chirpy config check`: it prints the settings
+// the server would start with, or every problem that would stop it.
+func runConfigCheck(cfg config.Config, loadErr error) error {
+	err := loadErr
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	cfg.Write(os.Stdout)
+	fmt.Println("configuration OK")
+	return nil
+}
@@ -0,0 +1,141 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ContentType = "application/activity+json"
+	// LDContentType is the other media type servers send and accept for
+	// ActivityStreams documents.
+	LDContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+	Context          = "https://www.w3.org/ns/activitystreams"
+	SecurityContext  = "https://w3id.org/security/v1"
+	PublicCollection = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Following         string     `json:"following,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+type Note struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	AttributedTo string     `json:"attributedTo,omitempty"`
+	Content      string     `json:"content,omitempty"`
+	URL          string     `json:"url,omitempty"`
+	Published    *time.Time `json:"published,omitempty"`
+	Updated      *time.Time `json:"updated,omitempty"`
+	To           []string   `json:"to,omitempty"`
+	Cc           []string   `json:"cc,omitempty"`
+}
+
+// Activity is an activity as it is sent or received. Object is kept raw
+// because it may be a URI or an embedded object; see ObjectID.
+type Activity struct {
+	Context   any             `json:"@context,omitempty"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object,omitempty"`
+	Published *time.Time      `json:"published,omitempty"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+}
+
+type OrderedCollection struct {
+	Context    any    `json:"@context,omitempty"`
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	TotalItems int    `json:"totalItems"`
+	First      string `json:"first,omitempty"`
+	Last       string `json:"last,omitempty"`
+}
+
+type OrderedCollectionPage struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	PartOf       string     `json:"partOf"`
+	Next         string     `json:"next,omitempty"`
+	Prev         string     `json:"prev,omitempty"`
+	OrderedItems []Activity `json:"orderedItems"`
+}
+
+// Object is the part of an embedded object most handlers need.
+type Object struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Actor string `json:"actor,omitempty"`
+}
+
+// ObjectID returns the id of an object property that holds either a URI or
+// an embedded object.
+func ObjectID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id
+	}
+	var obj Object
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		return obj.ID
+	}
+	return ""
+}
+
+// NewObject marshals v for use as an activity's object.
+func NewObject(v any) json.RawMessage {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
+
+// WebFinger is a JSON Resource Descriptor (RFC 7033).
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+// ActorURL returns the ActivityPub actor linked from a WebFinger response.
+func (w WebFinger) ActorURL() string {
+	for _, link := range w.Links {
+		if link.Rel == "self" && (link.Type == ContentType || link.Type == LDContentType) {
+			return link.Href
+		}
+	}
+	return ""
+}
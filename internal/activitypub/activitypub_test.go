@@ -0,0 +1,105 @@
+package activitypub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerifyRequest(t *testing.T) {
+	publicPEM, privatePEM, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, err := ParsePrivateKey(privatePEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := ParsePublicKey(publicPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	body := []byte(`{"type":"Follow"}`)
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://chirpy.example/ap/users/1/inbox", bytes.NewReader(body))
+		if err := SignRequest(req, body, "http://other.example/ap/users/2#main-key", privateKey, now); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := newRequest()
+	keyId, err := SignatureKeyID(req)
+	if err != nil || keyId != "http://other.example/ap/users/2#main-key" {
+		t.Fatalf("unexpected keyId %q, %v", keyId, err)
+	}
+	if err := VerifyRequest(req, body, publicKey, now, time.Minute); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(req *http.Request) []byte
+	}{
+		{"tampered body", func(req *http.Request) []byte { return []byte(`{"type":"Delete"}`) }},
+		{"tampered digest", func(req *http.Request) []byte {
+			changed := []byte(`{"type":"Delete"}`)
+			req.Header.Set("Digest", Digest(changed))
+			return changed
+		}},
+		{"different target", func(req *http.Request) []byte {
+			req.URL.Path = "/ap/users/3/inbox"
+			return body
+		}},
+		{"stale date", func(req *http.Request) []byte {
+			req.Header.Set("Date", now.Add(-time.Hour).UTC().Format(http.TimeFormat))
+			return body
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest()
+			reqBody := tt.modify(req)
+			if err := VerifyRequest(req, reqBody, publicKey, now, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("expected ErrInvalidSignature, got %v", err)
+			}
+		})
+	}
+
+	unsigned := httptest.NewRequest(http.MethodPost, "http://chirpy.example/ap/users/1/inbox", nil)
+	if err := VerifyRequest(unsigned, nil, publicKey, now, time.Minute); !errors.Is(err, ErrNoSignature) {
+		t.Fatalf("expected ErrNoSignature, got %v", err)
+	}
+}
+
+func TestObjectID(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`"https://chirpy.example/ap/chirps/1"`, "https://chirpy.example/ap/chirps/1"},
+		{`{"id": "https://chirpy.example/ap/chirps/1", "type": "Note"}`, "https://chirpy.example/ap/chirps/1"},
+		{`42`, ""},
+	}
+	for _, tt := range tests {
+		if got := ObjectID(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("ObjectID(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestWebFingerActorURL(t *testing.T) {
+	jrd := WebFinger{Links: []WebFingerLink{
+		{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: "https://chirpy.example/@alice"},
+		{Rel: "self", Type: ContentType, Href: "https://chirpy.example/ap/users/1"},
+	}}
+	if got := jrd.ActorURL(); got != "https://chirpy.example/ap/users/1" {
+		t.Fatalf("unexpected actor URL %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxDocumentSize caps how much of a remote document is read.
+const maxDocumentSize = 1 << 20
+
+// Fetch GETs an ActivityStreams document and decodes it into v.
+func Fetch(ctx context.Context, client *http.Client, uri string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", ContentType+", "+LDContentType)
+
+	return do(client, req, v)
+}
+
+// LookupAccount resolves an account such as "alice@example.com" to its
+// actor URL with WebFinger. scheme is "https" except when federating with
+// local test instances.
+func LookupAccount(ctx context.Context, client *http.Client, scheme, account string) (string, error) {
+	account = strings.TrimPrefix(account, "@")
+	_, host, ok := strings.Cut(account, "@")
+	if !ok || host == "" {
+		return "", fmt.Errorf("invalid account %q", account)
+	}
+
+	query := url.Values{"resource": {"acct:" + account}}
+	uri := scheme + "://" + host + "/.well-known/webfinger?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/jrd+json, application/json")
+
+	var jrd WebFinger
+	if err := do(client, req, &jrd); err != nil {
+		return "", err
+	}
+	actorURL := jrd.ActorURL()
+	if actorURL == "" {
+		return "", fmt.Errorf("%s has no ActivityPub actor", account)
+	}
+	return actorURL, nil
+}
+
+// Deliver POSTs an activity to an inbox, signed with the sending actor's
+// key. It returns the response status, or 0 if no response was received.
+func Deliver(ctx context.Context, client *http.Client, inbox string, activity []byte, keyId string, key *rsa.PrivateKey) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(activity))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", ContentType)
+
+	if err := SignRequest(req, activity, keyId, key, time.Now()); err != nil {
+		return 0, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxDocumentSize))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("inbox responded with %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func do(client *http.Client, req *http.Request, v any) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", req.URL, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxDocumentSize)).Decode(v)
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
@@ -90,3 +92,24 @@ func MakeRefreshToken() (string, error) {
 	encodedStr := hex.EncodeToString(randBytes)
 	return encodedStr, nil
 }
+
+// MakeDownloadSignature signs a resource id together with its expiry so a
+// download link can be shared without an access token.
+func MakeDownloadSignature(resourceId string, expiresAt time.Time, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s.%d", resourceId, expiresAt.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateDownloadSignature checks a signature made by MakeDownloadSignature
+// and that the link hasn't expired by now.
+func ValidateDownloadSignature(resourceId string, expiresAt, now time.Time, signature, secret string) error {
+	if now.After(expiresAt) {
+		return fmt.Errorf("download link expired")
+	}
+	expected := MakeDownloadSignature(resourceId, expiresAt, secret)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid download signature")
+	}
+	return nil
+}
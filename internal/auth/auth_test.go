@@ -58,3 +58,25 @@ func TestValidateJWT(t *testing.T) {
 		t.Fatalf("expected subject to be %v, got %v", userId, subject)
 	}
 }
+
+func TestValidateDownloadSignature(t *testing.T) {
+	secret := "secret"
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	signature := MakeDownloadSignature("export123", expiresAt, secret)
+
+	err := ValidateDownloadSignature("export123", expiresAt, now, signature, secret)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = ValidateDownloadSignature("export456", expiresAt, now, signature, secret)
+	if err == nil {
+		t.Fatalf("expected error for signature of a different resource")
+	}
+
+	err = ValidateDownloadSignature("export123", expiresAt, expiresAt.Add(time.Minute), signature, secret)
+	if err == nil {
+		t.Fatalf("expected error for expired link")
+	}
+}
@@ -0,0 +1,66 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFileStorePutGet(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = store.Put(context.Background(), "exports/abc.zip", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	rc, err := store.Get(context.Background(), "exports/abc.zip")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("expected blob to be %q, got %q", "hello", string(data))
+	}
+}
+
+func TestFileStoreRejectsTraversal(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = store.Put(context.Background(), "../outside", strings.NewReader("nope"))
+	if err == nil {
+		t.Fatalf("expected error for key outside the store")
+	}
+}
+
+func TestFileStoreDelete(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err = store.Put(context.Background(), "a", strings.NewReader("x"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	err = store.Delete(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, err = store.Get(context.Background(), "a")
+	if err == nil {
+		t.Fatalf("expected error reading deleted blob")
+	}
+}
@@ -0,0 +1,258 @@
+// Package config loads Chirpy's settings from the environment, a .env file
+// and an optional YAML file, and checks them before the server starts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is read when CHIRPY_CONFIG doesn't name another file.
+const DefaultFile = "chirpy.yaml"
+
+// Config holds every setting. Each field can be set in the YAML file under
+// its yaml name or in the environment under its env name.
+type Config struct {
+	Platform    string `yaml:"platform" env:"PLATFORM"`
+	Port        int    `yaml:"port" env:"PORT"`
+	BaseURL     string `yaml:"base_url" env:"BASE_URL"`
+	DatabaseURL string `yaml:"db_url" env:"DB_URL" secret:"password"`
+	AutoMigrate bool   `yaml:"auto_migrate" env:"AUTO_MIGRATE"`
+	BlobDir     string `yaml:"blob_dir" env:"BLOB_DIR"`
+	LogFormat   string `yaml:"log_format" env:"LOG_FORMAT"`
+	LogLevel    string `yaml:"log_level" env:"LOG_LEVEL"`
+
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	MaxBodyBytes      int64         `yaml:"max_body_bytes" env:"MAX_BODY_BYTES"`
+
+	TokenSecret     string        `yaml:"token_secret" env:"TOKEN_SECRET" secret:"true"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" env:"REFRESH_TOKEN_TTL"`
+	DeletionGrace   time.Duration `yaml:"user_deletion_grace_period" env:"USER_DELETION_GRACE_PERIOD"`
+
+	PolkaKey           string `yaml:"polka_key" env:"POLKA_KEY" secret:"true"`
+	PolkaWebhookSecret string `yaml:"polka_webhook_secret" env:"POLKA_WEBHOOK_SECRET" secret:"true"`
+
+	MetricsToken string `yaml:"metrics_token" env:"METRICS_TOKEN" secret:"true"`
+
+	SMTPAddr     string `yaml:"smtp_addr" env:"SMTP_ADDR"`
+	SMTPFrom     string `yaml:"smtp_from" env:"SMTP_FROM"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// Default returns the settings used for anything left unset.
+func Default() Config {
+	return Config{
+		Port:              8080,
+		BlobDir:           "data",
+		LogFormat:         "text",
+		LogLevel:          "info",
+		ReadTimeout:       time.Second * 15,
+		ReadHeaderTimeout: time.Second * 5,
+		WriteTimeout:      time.Second * 30,
+		IdleTimeout:       time.Minute * 2,
+		ShutdownTimeout:   time.Second * 30,
+		MaxBodyBytes:      64 << 10,
+		AccessTokenTTL:    time.Minute * 5,
+		RefreshTokenTTL:   time.Hour * 24 * 60,
+		DeletionGrace:     time.Hour * 24 * 30,
+	}
+}
+
+// Load starts from the defaults, then applies the YAML file named by
+// CHIRPY_CONFIG (or DefaultFile, if it exists), then .env, then the
+// environment, so later sources win. Missing .env and default files are
+// skipped. The returned error lists every value that could not be parsed;
+// call Validate to check the result.
+func Load() (Config, error) {
+	cfg := Default()
+
+	path, explicit := os.LookupEnv("CHIRPY_CONFIG")
+	if !explicit {
+		path = DefaultFile
+	}
+	err := cfg.loadFile(path)
+	if err != nil && (explicit || !errors.Is(err, fs.ErrNotExist)) {
+		return cfg, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return cfg, fmt.Errorf("reading .env: %w", err)
+	}
+	lookup := func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := dotenv[key]
+		return value, ok
+	}
+
+	return cfg, errors.Join(cfg.loadEnv(lookup)...)
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(c)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (c *Config) loadEnv(lookup func(string) (string, bool)) []error {
+	var problems []error
+	v := reflect.ValueOf(c).Elem()
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		raw, ok := lookup(field.Tag.Get("env"))
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch dst := v.Field(i); {
+		case dst.Type() == durationType:
+			var d time.Duration
+			d, err = time.ParseDuration(raw)
+			dst.SetInt(int64(d))
+		case dst.CanInt():
+			var n int64
+			n, err = strconv.ParseInt(raw, 10, 64)
+			dst.SetInt(n)
+		case dst.Kind() == reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(raw)
+			dst.SetBool(b)
+		default:
+			dst.SetString(raw)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: cannot parse %q", field.Tag.Get("env"), raw))
+		}
+	}
+	return problems
+}
+
+// Validate returns an error listing every problem with c, or nil.
+func (c *Config) Validate() error {
+	var problems []error
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.TokenSecret == "" {
+		problem("TOKEN_SECRET is required")
+	}
+	if c.DatabaseURL == "" {
+		problem("DB_URL is required")
+	}
+	if c.Platform != "dev" && (c.PolkaKey == "" || c.PolkaWebhookSecret == "") {
+		problem("POLKA_KEY and POLKA_WEBHOOK_SECRET are required unless PLATFORM is dev")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		problem("PORT must be between 1 and 65535, got %d", c.Port)
+	}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			problem("BASE_URL must be an absolute URL, got %q", c.BaseURL)
+		}
+	}
+	if c.AccessTokenTTL <= 0 {
+		problem("ACCESS_TOKEN_TTL must be positive")
+	}
+	if c.RefreshTokenTTL <= c.AccessTokenTTL {
+		problem("REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL")
+	}
+	if c.DeletionGrace < 0 {
+		problem("USER_DELETION_GRACE_PERIOD must not be negative")
+	}
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"READ_TIMEOUT", c.ReadTimeout},
+		{"READ_HEADER_TIMEOUT", c.ReadHeaderTimeout},
+		{"WRITE_TIMEOUT", c.WriteTimeout},
+		{"IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			problem("%s must be positive", timeout.name)
+		}
+	}
+	if c.MaxBodyBytes <= 0 {
+		problem("MAX_BODY_BYTES must be positive")
+	}
+	if c.BlobDir == "" {
+		problem("BLOB_DIR must not be empty")
+	}
+	if f := strings.ToLower(c.LogFormat); f != "json" && f != "text" {
+		problem("LOG_FORMAT must be json or text, got %q", c.LogFormat)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		problem("LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.SMTPAddr != "" && c.SMTPFrom == "" {
+		problem("SMTP_FROM is required when SMTP_ADDR is set")
+	}
+	return errors.Join(problems...)
+}
+
+// PublicBaseURL is BaseURL, or the local address when it is unset.
+func (c *Config) PublicBaseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+	return fmt.Sprintf("http://localhost:%d", c.Port)
+}
+
+// Write prints every setting under its env name, hiding secrets and the
+// password in DB_URL.
+func (c *Config) Write(w io.Writer) {
+	v := reflect.ValueOf(c).Elem()
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		value := fmt.Sprint(v.Field(i).Interface())
+		switch field.Tag.Get("secret") {
+		case "true":
+			if value != "" {
+				value = "(set)"
+			}
+		case "password":
+			if u, err := url.Parse(value); err == nil {
+				value = u.Redacted()
+			}
+		}
+		fmt.Fprintf(w, "%s=%s\n", field.Tag.Get("env"), value)
+	}
+}
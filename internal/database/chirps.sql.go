@@ -8,6 +8,8 @@ package database
 import (
 	"context"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
@@ -53,24 +55,35 @@ func (q *Queries) DeleteChirpById(ctx context.Context, id string) error {
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, body, created_at, updated_at, user_id FROM chirps ORDER BY created_at ASC
+SELECT chirps.id, chirps.body, chirps.created_at, chirps.updated_at, chirps.user_id, users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.delete_after IS NULL
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
+type GetAllChirpsRow struct {
+	Chirp       Chirp
+	Handle      string
+	DisplayName string
+}
+
+func (q *Queries) GetAllChirps(ctx context.Context) ([]GetAllChirpsRow, error) {
 	rows, err := q.db.QueryContext(ctx, getAllChirps)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []GetAllChirpsRow
 	for rows.Next() {
-		var i Chirp
+		var i GetAllChirpsRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.Body,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.UserID,
+			&i.Chirp.ID,
+			&i.Chirp.Body,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.UserID,
+			&i.Handle,
+			&i.DisplayName,
 		); err != nil {
 			return nil, err
 		}
@@ -86,24 +99,35 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getAllUserChirps = `-- name: GetAllUserChirps :many
-SELECT id, body, created_at, updated_at, user_id FROM chirps WHERE user_id = $1 ORDER BY created_at ASC
+SELECT chirps.id, chirps.body, chirps.created_at, chirps.updated_at, chirps.user_id, users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.delete_after IS NULL
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetAllUserChirps(ctx context.Context, userID string) ([]Chirp, error) {
+type GetAllUserChirpsRow struct {
+	Chirp       Chirp
+	Handle      string
+	DisplayName string
+}
+
+func (q *Queries) GetAllUserChirps(ctx context.Context, userID string) ([]GetAllUserChirpsRow, error) {
 	rows, err := q.db.QueryContext(ctx, getAllUserChirps, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []GetAllUserChirpsRow
 	for rows.Next() {
-		var i Chirp
+		var i GetAllUserChirpsRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.Body,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.UserID,
+			&i.Chirp.ID,
+			&i.Chirp.Body,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.UserID,
+			&i.Handle,
+			&i.DisplayName,
 		); err != nil {
 			return nil, err
 		}
@@ -119,11 +143,213 @@ func (q *Queries) GetAllUserChirps(ctx context.Context, userID string) ([]Chirp,
 }
 
 const getChirpById = `-- name: GetChirpById :one
-SELECT id, body, created_at, updated_at, user_id FROM chirps WHERE id = $1 LIMIT 1
+SELECT chirps.id, chirps.body, chirps.created_at, chirps.updated_at, chirps.user_id, users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.delete_after IS NULL
+LIMIT 1
 `
 
-func (q *Queries) GetChirpById(ctx context.Context, id string) (Chirp, error) {
+type GetChirpByIdRow struct {
+	Chirp       Chirp
+	Handle      string
+	DisplayName string
+}
+
+func (q *Queries) GetChirpById(ctx context.Context, id string) (GetChirpByIdRow, error) {
 	row := q.db.QueryRowContext(ctx, getChirpById, id)
+	var i GetChirpByIdRow
+	err := row.Scan(
+		&i.Chirp.ID,
+		&i.Chirp.Body,
+		&i.Chirp.CreatedAt,
+		&i.Chirp.UpdatedAt,
+		&i.Chirp.UserID,
+		&i.Handle,
+		&i.DisplayName,
+	)
+	return i, err
+}
+
+const getHashtagChirpsPage = `-- name: GetHashtagChirpsPage :many
+SELECT chirps.id, chirps.body, chirps.created_at, chirps.updated_at, chirps.user_id, users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags(chirps.body) @> ARRAY[$1::text] AND users.delete_after IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetHashtagChirpsPageParams struct {
+	Hashtag string
+	Limit   int32
+	Offset  int32
+}
+
+type GetHashtagChirpsPageRow struct {
+	Chirp       Chirp
+	Handle      string
+	DisplayName string
+}
+
+func (q *Queries) GetHashtagChirpsPage(ctx context.Context, arg GetHashtagChirpsPageParams) ([]GetHashtagChirpsPageRow, error) {
+	rows, err := q.db.QueryContext(ctx, getHashtagChirpsPage, arg.Hashtag, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetHashtagChirpsPageRow
+	for rows.Next() {
+		var i GetHashtagChirpsPageRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.Body,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.UserID,
+			&i.Handle,
+			&i.DisplayName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getHashtagChirpsSummary = `-- name: GetHashtagChirpsSummary :one
+SELECT count(*) AS chirp_count, COALESCE(max(chirps.updated_at), 'epoch')::timestamp AS last_updated FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags(chirps.body) @> ARRAY[$1::text] AND users.delete_after IS NULL
+`
+
+type GetHashtagChirpsSummaryRow struct {
+	ChirpCount  int64
+	LastUpdated time.Time
+}
+
+func (q *Queries) GetHashtagChirpsSummary(ctx context.Context, hashtag string) (GetHashtagChirpsSummaryRow, error) {
+	row := q.db.QueryRowContext(ctx, getHashtagChirpsSummary, hashtag)
+	var i GetHashtagChirpsSummaryRow
+	err := row.Scan(&i.ChirpCount, &i.LastUpdated)
+	return i, err
+}
+
+const getUserChirpsPage = `-- name: GetUserChirpsPage :many
+SELECT chirps.id, chirps.body, chirps.created_at, chirps.updated_at, chirps.user_id, users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.delete_after IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetUserChirpsPageParams struct {
+	UserID string
+	Limit  int32
+	Offset int32
+}
+
+type GetUserChirpsPageRow struct {
+	Chirp       Chirp
+	Handle      string
+	DisplayName string
+}
+
+func (q *Queries) GetUserChirpsPage(ctx context.Context, arg GetUserChirpsPageParams) ([]GetUserChirpsPageRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserChirpsPage, arg.UserID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserChirpsPageRow
+	for rows.Next() {
+		var i GetUserChirpsPageRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.Body,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.UserID,
+			&i.Handle,
+			&i.DisplayName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserChirpsSummary = `-- name: GetUserChirpsSummary :one
+SELECT count(*) AS chirp_count, COALESCE(max(chirps.updated_at), 'epoch')::timestamp AS last_updated FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.delete_after IS NULL
+`
+
+type GetUserChirpsSummaryRow struct {
+	ChirpCount  int64
+	LastUpdated time.Time
+}
+
+func (q *Queries) GetUserChirpsSummary(ctx context.Context, userID string) (GetUserChirpsSummaryRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserChirpsSummary, userID)
+	var i GetUserChirpsSummaryRow
+	err := row.Scan(&i.ChirpCount, &i.LastUpdated)
+	return i, err
+}
+
+const getVisibleChirpIds = `-- name: GetVisibleChirpIds :many
+SELECT chirps.id FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = ANY($1::text[]) AND users.delete_after IS NULL
+`
+
+func (q *Queries) GetVisibleChirpIds(ctx context.Context, ids []string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getVisibleChirpIds, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $1, updated_at = $2 WHERE id = $3
+RETURNING id, body, created_at, updated_at, user_id
+`
+
+type UpdateChirpBodyParams struct {
+	Body      string
+	UpdatedAt time.Time
+	ID        string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.UpdatedAt, arg.ID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
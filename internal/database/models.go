@@ -9,6 +9,13 @@ import (
 	"time"
 )
 
+type ActorKey struct {
+	UserID        string
+	PublicKeyPem  string
+	PrivateKeyPem string
+	CreatedAt     time.Time
+}
+
 type Chirp struct {
 	ID        string
 	Body      string
@@ -17,6 +24,43 @@ type Chirp struct {
 	UserID    string
 }
 
+type FederationDelivery struct {
+	ID            string
+	UserID        string
+	Inbox         string
+	Activity      string
+	Status        string
+	Attempts      int32
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+type Follow struct {
+	FollowerID string
+	FolloweeID string
+	CreatedAt  time.Time
+}
+
+type Notification struct {
+	ID            string
+	UserID        string
+	Type          string
+	ActorID       sql.NullString
+	ChirpID       sql.NullString
+	GroupKey      string
+	ReadAt        sql.NullTime
+	CreatedAt     time.Time
+	RemoteActorID sql.NullString
+}
+
+type NotificationPreference struct {
+	UserID    string
+	Type      string
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
 type RefreshToken struct {
 	Token     string
 	CreatedAt time.Time
@@ -26,11 +70,150 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type RemoteActor struct {
+	ID                string
+	PreferredUsername string
+	Name              string
+	Url               string
+	Inbox             string
+	SharedInbox       string
+	PublicKeyID       string
+	PublicKeyPem      string
+	FetchedAt         time.Time
+}
+
+type RemoteFollower struct {
+	UserID     string
+	ActorID    string
+	ActivityID string
+	CreatedAt  time.Time
+}
+
+type RemoteFollowing struct {
+	UserID     string
+	ActorID    string
+	ActivityID string
+	Accepted   bool
+	CreatedAt  time.Time
+}
+
+type RemoteLike struct {
+	ChirpID    string
+	ActorID    string
+	ActivityID string
+	CreatedAt  time.Time
+}
+
+type RemoteNote struct {
+	ID        string
+	ActorID   string
+	Content   string
+	Url       string
+	Published time.Time
+	CreatedAt time.Time
+}
+
+type StreamEvent struct {
+	ID          int64
+	Event       string
+	AuthorID    string
+	Hashtags    []string
+	Payload     string
+	CreatedAt   time.Time
+	RecipientID sql.NullString
+}
+
+type Subscription struct {
+	ID                 string
+	UserID             string
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	CancelAt           sql.NullTime
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
 type User struct {
-	ID             string
-	Email          string
-	HashedPassword string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	IsChirpyRed    bool
+	ID                         string
+	Email                      string
+	HashedPassword             string
+	CreatedAt                  time.Time
+	UpdatedAt                  time.Time
+	IsChirpyRed                bool
+	DeleteAfter                sql.NullTime
+	Handle                     string
+	DisplayName                string
+	Bio                        string
+	AvatarUrl                  string
+	Website                    string
+	PendingEmail               sql.NullString
+	EmailVerificationToken     sql.NullString
+	EmailVerificationExpiresAt sql.NullTime
+}
+
+type UserExport struct {
+	ID        string
+	UserID    string
+	Status    string
+	BlobKey   sql.NullString
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type UserSuggestion struct {
+	UserID          string
+	SuggestedUserID string
+	Score           float64
+	ComputedAt      time.Time
+}
+
+type UserSuggestionRun struct {
+	UserID     string
+	ComputedAt time.Time
+}
+
+type WebhookEvent struct {
+	ID          string
+	Provider    string
+	Event       string
+	Payload     string
+	Status      string
+	Error       sql.NullString
+	ReceivedAt  time.Time
+	ProcessedAt sql.NullTime
+	ClaimedAt   sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID              string
+	EndpointID      string
+	EventID         string
+	Event           string
+	Payload         string
+	Status          string
+	Attempts        int32
+	NextAttemptAt   time.Time
+	LastAttemptedAt sql.NullTime
+	CreatedAt       time.Time
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          string
+	DeliveryID  string
+	StatusCode  sql.NullInt32
+	Error       sql.NullString
+	DurationMs  int32
+	AttemptedAt time.Time
+}
+
+type WebhookEndpoint struct {
+	ID        string
+	UserID    string
+	Url       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
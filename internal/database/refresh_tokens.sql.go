@@ -47,22 +47,38 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 	return i, err
 }
 
-const deleteAllTokens = `-- name: DeleteAllTokens :exec
-DELETE FROM refresh_tokens
+const getAllUserTokens = `-- name: GetAllUserTokens :many
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens WHERE user_id = $1 ORDER BY created_at ASC
 `
 
-func (q *Queries) DeleteAllTokens(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, deleteAllTokens)
-	return err
-}
-
-const deleteUserToken = `-- name: DeleteUserToken :exec
-DELETE FROM refresh_tokens WHERE user_id = $1
-`
-
-func (q *Queries) DeleteUserToken(ctx context.Context, userID string) error {
-	_, err := q.db.ExecContext(ctx, deleteUserToken, userID)
-	return err
+func (q *Queries) GetAllUserTokens(ctx context.Context, userID string) ([]RefreshToken, error) {
+	rows, err := q.db.QueryContext(ctx, getAllUserTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RefreshToken
+	for rows.Next() {
+		var i RefreshToken
+		if err := rows.Scan(
+			&i.Token,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const getToken = `-- name: GetToken :one
@@ -83,22 +99,19 @@ func (q *Queries) GetToken(ctx context.Context, token string) (RefreshToken, err
 	return i, err
 }
 
-const getTokenByUserId = `-- name: GetTokenByUserId :one
-SELECT token, created_at, updated_at, user_id, expires_at, revoked_at FROM refresh_tokens WHERE user_id = $1 LIMIT 1
+const revokeAllUserTokens = `-- name: RevokeAllUserTokens :exec
+UPDATE refresh_tokens SET revoked_at = $1, updated_at = $2 WHERE user_id = $3 AND revoked_at IS NULL
 `
 
-func (q *Queries) GetTokenByUserId(ctx context.Context, userID string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, getTokenByUserId, userID)
-	var i RefreshToken
-	err := row.Scan(
-		&i.Token,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.UserID,
-		&i.ExpiresAt,
-		&i.RevokedAt,
-	)
-	return i, err
+type RevokeAllUserTokensParams struct {
+	RevokedAt sql.NullTime
+	UpdatedAt time.Time
+	UserID    string
+}
+
+func (q *Queries) RevokeAllUserTokens(ctx context.Context, arg RevokeAllUserTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeAllUserTokens, arg.RevokedAt, arg.UpdatedAt, arg.UserID)
+	return err
 }
 
 const revokeToken = `-- name: RevokeToken :exec
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SQLStore runs queries against a connection pool and adds transactions.
+type SQLStore struct {
+	*Queries
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{Queries: New(db), db: db}
+}
+
+// InTx runs fn in a transaction that is committed if fn returns nil.
+func (s *SQLStore) InTx(ctx context.Context, fn func(Querier) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(s.Queries.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the connection pool.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// Stats returns the connection pool statistics.
+func (s *SQLStore) Stats() sql.DBStats {
+	return s.db.Stats()
+}
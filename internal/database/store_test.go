@@ -0,0 +1,46 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/migrations"
+	"github.com/gaba-bouliva/Chirpy/internal/store"
+	"github.com/gaba-bouliva/Chirpy/internal/store/storetest"
+	_ "github.com/lib/pq"
+)
+
+// TestSQLStore migrates the database named by CHIRPY_TEST_POSTGRES_URL and
+// deletes every user in it.
+func TestSQLStore(t *testing.T) {
+	url := os.Getenv("CHIRPY_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("CHIRPY_TEST_POSTGRES_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	provider, err := migrations.Postgres(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		// deleting users deletes their chirps and refresh tokens too
+		_, err := db.ExecContext(context.Background(), "DELETE FROM users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database.NewSQLStore(db)
+	})
+}
@@ -7,13 +7,62 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users set delete_after = NULL, updated_at = $1 WHERE id = $2
+`
+
+type CancelUserDeletionParams struct {
+	UpdatedAt time.Time
+	ID        string
+}
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, arg CancelUserDeletionParams) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const confirmUserEmail = `-- name: ConfirmUserEmail :one
+UPDATE users set email = pending_email, pending_email = NULL, email_verification_token = NULL, email_verification_expires_at = NULL, updated_at = $1
+WHERE id = $2 AND pending_email IS NOT NULL
+RETURNING id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at
+`
+
+type ConfirmUserEmailParams struct {
+	UpdatedAt time.Time
+	ID        string
+}
+
+func (q *Queries) ConfirmUserEmail(ctx context.Context, arg ConfirmUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, confirmUserEmail, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, email, hashed_password, created_at, updated_at, is_chirpy_red
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle, display_name)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at
 `
 
 type CreateUserParams struct {
@@ -22,6 +71,8 @@ type CreateUserParams struct {
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
+	Handle         string
+	DisplayName    string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -31,6 +82,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.UpdatedAt,
 		arg.Email,
 		arg.HashedPassword,
+		arg.Handle,
+		arg.DisplayName,
 	)
 	var i User
 	err := row.Scan(
@@ -40,21 +93,33 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
 	)
 	return i, err
 }
 
-const deleteAllUsers = `-- name: DeleteAllUsers :exec
-DELETE FROM users
+const deleteUsersDueForDeletion = `-- name: DeleteUsersDueForDeletion :execrows
+DELETE FROM users WHERE delete_after IS NOT NULL AND delete_after <= $1
 `
 
-func (q *Queries) DeleteAllUsers(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, deleteAllUsers)
-	return err
+func (q *Queries) DeleteUsersDueForDeletion(ctx context.Context, deleteAfter sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUsersDueForDeletion, deleteAfter)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, hashed_password, created_at, updated_at, is_chirpy_red FROM users WHERE email = $1 LIMIT 1
+SELECT id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at FROM users WHERE email = $1 LIMIT 1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -67,12 +132,75 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
+	)
+	return i, err
+}
+
+const getUserByEmailVerificationToken = `-- name: GetUserByEmailVerificationToken :one
+SELECT id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at FROM users WHERE email_verification_token = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByEmailVerificationToken(ctx context.Context, emailVerificationToken sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmailVerificationToken, emailVerificationToken)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at FROM users WHERE handle = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, email, hashed_password, created_at, updated_at, is_chirpy_red FROM users WHERE id = $1 LIMIT 1
+SELECT id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at FROM users WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetUserById(ctx context.Context, id string) (User, error) {
@@ -85,27 +213,154 @@ func (q *Queries) GetUserById(ctx context.Context, id string) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
 	)
 	return i, err
 }
 
-const updateUser = `-- name: UpdateUser :one
-UPDATE users set email = $1, hashed_password = $2, updated_at = $3 
-WHERE id = $4
-RETURNING id, email, hashed_password, created_at, updated_at, is_chirpy_red
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :one
+UPDATE users set delete_after = $1, updated_at = $2 WHERE id = $3
+RETURNING id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at
 `
 
-type UpdateUserParams struct {
-	Email          string
+type ScheduleUserDeletionParams struct {
+	DeleteAfter sql.NullTime
+	UpdatedAt   time.Time
+	ID          string
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, scheduleUserDeletion, arg.DeleteAfter, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
+	)
+	return i, err
+}
+
+const setUserPendingEmail = `-- name: SetUserPendingEmail :one
+UPDATE users set pending_email = $1, email_verification_token = $2, email_verification_expires_at = $3, updated_at = $4
+WHERE id = $5
+RETURNING id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at
+`
+
+type SetUserPendingEmailParams struct {
+	PendingEmail               sql.NullString
+	EmailVerificationToken     sql.NullString
+	EmailVerificationExpiresAt sql.NullTime
+	UpdatedAt                  time.Time
+	ID                         string
+}
+
+func (q *Queries) SetUserPendingEmail(ctx context.Context, arg SetUserPendingEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserPendingEmail,
+		arg.PendingEmail,
+		arg.EmailVerificationToken,
+		arg.EmailVerificationExpiresAt,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users set hashed_password = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at
+`
+
+type UpdateUserPasswordParams struct {
 	HashedPassword string
 	UpdatedAt      time.Time
 	ID             string
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser,
-		arg.Email,
-		arg.HashedPassword,
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.HashedPassword, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users set handle = $1, display_name = $2, bio = $3, avatar_url = $4, website = $5, updated_at = $6
+WHERE id = $7
+RETURNING id, email, hashed_password, created_at, updated_at, is_chirpy_red, delete_after, handle, display_name, bio, avatar_url, website, pending_email, email_verification_token, email_verification_expires_at
+`
+
+type UpdateUserProfileParams struct {
+	Handle      string
+	DisplayName string
+	Bio         string
+	AvatarUrl   string
+	Website     string
+	UpdatedAt   time.Time
+	ID          string
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.Website,
 		arg.UpdatedAt,
 		arg.ID,
 	)
@@ -117,11 +372,20 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsChirpyRed,
+		&i.DeleteAfter,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Website,
+		&i.PendingEmail,
+		&i.EmailVerificationToken,
+		&i.EmailVerificationExpiresAt,
 	)
 	return i, err
 }
 
-const updateUserSetChirpyRed = `-- name: UpdateUserSetChirpyRed :exec
+const updateUserSetChirpyRed = `-- name: UpdateUserSetChirpyRed :execrows
 UPDATE users set is_chirpy_red = $1 WHERE id = $2
 `
 
@@ -130,7 +394,10 @@ type UpdateUserSetChirpyRedParams struct {
 	ID          string
 }
 
-func (q *Queries) UpdateUserSetChirpyRed(ctx context.Context, arg UpdateUserSetChirpyRedParams) error {
-	_, err := q.db.ExecContext(ctx, updateUserSetChirpyRed, arg.IsChirpyRed, arg.ID)
-	return err
+func (q *Queries) UpdateUserSetChirpyRed(ctx context.Context, arg UpdateUserSetChirpyRedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserSetChirpyRed, arg.IsChirpyRed, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,45 @@
+package entitlements
+
+import "time"
+
+const (
+	PlanFree      = "free"
+	PlanChirpyRed = "chirpy_red"
+)
+
+// Capabilities are the limits a plan grants. Handlers look them up once per
+// request instead of checking plan names themselves.
+type Capabilities struct {
+	Plan            string
+	MaxChirpLength  int
+	EditWindow      time.Duration
+	ChirpsPerMinute int
+}
+
+var plans = map[string]Capabilities{
+	PlanFree: {
+		Plan:            PlanFree,
+		MaxChirpLength:  140,
+		EditWindow:      0,
+		ChirpsPerMinute: 10,
+	},
+	PlanChirpyRed: {
+		Plan:            PlanChirpyRed,
+		MaxChirpLength:  500,
+		EditWindow:      time.Minute * 15,
+		ChirpsPerMinute: 60,
+	},
+}
+
+// ForPlan returns the capabilities of plan, or the free plan's if the plan
+// is unknown.
+func ForPlan(plan string) Capabilities {
+	if caps, ok := plans[plan]; ok {
+		return caps
+	}
+	return plans[PlanFree]
+}
+
+func (c Capabilities) CanEdit(createdAt, now time.Time) bool {
+	return c.EditWindow > 0 && now.Sub(createdAt) <= c.EditWindow
+}
@@ -0,0 +1,37 @@
+package entitlements
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForPlanUnknownFallsBackToFree(t *testing.T) {
+	caps := ForPlan("platinum")
+	if caps.Plan != PlanFree {
+		t.Fatalf("expected plan to be %v, got %v", PlanFree, caps.Plan)
+	}
+}
+
+func TestChirpyRedAllowsLongerChirps(t *testing.T) {
+	free := ForPlan(PlanFree)
+	red := ForPlan(PlanChirpyRed)
+	if red.MaxChirpLength <= free.MaxChirpLength {
+		t.Fatalf("expected chirpy red limit %d to exceed free limit %d", red.MaxChirpLength, free.MaxChirpLength)
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+	now := time.Now()
+
+	if ForPlan(PlanFree).CanEdit(now, now) {
+		t.Fatalf("expected free plan to have no edit window")
+	}
+
+	red := ForPlan(PlanChirpyRed)
+	if !red.CanEdit(now.Add(-time.Minute), now) {
+		t.Fatalf("expected chirpy red to edit a chirp from a minute ago")
+	}
+	if red.CanEdit(now.Add(-time.Hour), now) {
+		t.Fatalf("expected chirpy red edit window to have passed after an hour")
+	}
+}
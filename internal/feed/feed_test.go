@@ -0,0 +1,86 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	published := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return Feed{
+		ID:      "https://chirpy.example/users/1",
+		Title:   "alice on Chirpy",
+		Link:    "https://chirpy.example/users/1",
+		Updated: published,
+		Links: Links{
+			Self: "https://chirpy.example/users/1/feed.atom?page=2",
+			Prev: "https://chirpy.example/users/1/feed.atom?page=1",
+		},
+		Entries: []Entry{{
+			ID:        "urn:uuid:8d6c0c1e-5c1b-4a4e-9f55-2b8c4ad8d3a1",
+			Title:     "hello <world> & #go",
+			Content:   "hello <world> & #go",
+			Author:    "alice",
+			Published: published,
+			Updated:   published,
+		}},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, err := testFeed().Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc atomFeed
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("expected well-formed Atom, got %v\n%s", err, body)
+	}
+	if len(doc.Entries) != 1 || doc.Entries[0].Content.Body != "hello <world> & #go" {
+		t.Fatalf("unexpected entries %+v", doc.Entries)
+	}
+	if doc.Updated != "2025-03-01T12:00:00Z" {
+		t.Fatalf("unexpected updated %q", doc.Updated)
+	}
+	if !strings.Contains(string(body), `rel="previous" href="https://chirpy.example/users/1/feed.atom?page=1"`) {
+		t.Fatalf("expected previous link in\n%s", body)
+	}
+	if strings.Contains(string(body), `rel="next"`) {
+		t.Fatalf("expected empty links to be left out")
+	}
+}
+
+func TestRSS(t *testing.T) {
+	body, err := testFeed().RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Channel struct {
+			Items []struct {
+				GUID struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					Value       string `xml:",chardata"`
+				} `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("expected well-formed RSS, got %v\n%s", err, body)
+	}
+	if len(doc.Channel.Items) != 1 {
+		t.Fatalf("expected one item, got %d", len(doc.Channel.Items))
+	}
+	item := doc.Channel.Items[0]
+	if item.GUID.IsPermaLink != "false" || item.GUID.Value != "urn:uuid:8d6c0c1e-5c1b-4a4e-9f55-2b8c4ad8d3a1" {
+		t.Fatalf("unexpected guid %+v", item.GUID)
+	}
+	if item.PubDate != "Sat, 01 Mar 2025 12:00:00 +0000" {
+		t.Fatalf("unexpected pubDate %q", item.PubDate)
+	}
+}
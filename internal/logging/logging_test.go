@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var out strings.Builder
+	logger, err := New(&out, "json", "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "user_id", "u1")
+	if strings.Contains(out.String(), "hidden") || !strings.Contains(out.String(), `"user_id":"u1"`) {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	logger, err = New(&out, "TEXT", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("shown", "user_id", "u1")
+	if !strings.Contains(out.String(), "msg=shown user_id=u1") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	if _, err := New(&out, "xml", "info"); err == nil {
+		t.Fatal("expected an unknown format to be an error")
+	}
+	if _, err := New(&out, "text", "loud"); err == nil {
+		t.Fatal("expected an unknown level to be an error")
+	}
+}
+
+func TestContext(t *testing.T) {
+	fallback := slog.Default()
+	if FromContext(context.Background(), fallback) != fallback {
+		t.Fatal("expected the fallback without a logger in the context")
+	}
+
+	logger := fallback.With("request_id", "r1")
+	ctx := NewContext(context.Background(), logger)
+	if FromContext(ctx, fallback) != logger {
+		t.Fatal("expected the logger stored in the context")
+	}
+}
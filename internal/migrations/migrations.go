@@ -0,0 +1,88 @@
+// Package migrations applies the schema migrations embedded in the binary.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/sql/schema"
+	sqliteschema "github.com/gaba-bouliva/Chirpy/sql/sqlite/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Commands lists the arguments Run accepts.
+const Commands = "up, down, status or redo"
+
+// Postgres returns a migration provider for sql/schema. Every run holds a
+// session-level advisory lock, so servers starting at the same time migrate
+// one after another instead of at once.
+func Postgres(db *sql.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, schema.FS, goose.WithSessionLocker(locker))
+}
+
+// SQLite returns a migration provider for sql/sqlite/schema.
+func SQLite(db *sql.DB) (*goose.Provider, error) {
+	return goose.NewProvider(goose.DialectSQLite3, db, sqliteschema.FS)
+}
+
+// Run applies, rolls back or reports on migrations and writes what it did
+// to w. up applies every pending migration, down rolls back the latest one,
+// redo rolls back the latest one and applies it again.
+func Run(ctx context.Context, p *goose.Provider, command string, w io.Writer) error {
+	switch command {
+	case "up":
+		results, err := p.Up(ctx)
+		for _, result := range results {
+			fmt.Fprintln(w, result)
+		}
+		if err == nil && len(results) == 0 {
+			fmt.Fprintln(w, "no migrations to apply")
+		}
+		return err
+
+	case "down":
+		result, err := p.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, result)
+		return nil
+
+	case "redo":
+		result, err := p.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, result)
+		result, err = p.ApplyVersion(ctx, result.Source.Version, true)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, result)
+		return nil
+
+	case "status":
+		statuses, err := p.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%-25s %s\n", appliedAt, path.Base(status.Source.Path))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q, want %s", command, Commands)
+}
@@ -0,0 +1,74 @@
+package migrations_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gaba-bouliva/Chirpy/internal/migrations"
+	"github.com/gaba-bouliva/Chirpy/internal/sqlite"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.OpenDB("sqlite://" + filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	provider, err := migrations.SQLite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(command string) string {
+		t.Helper()
+		var out bytes.Buffer
+		err := migrations.Run(ctx, provider, command, &out)
+		if err != nil {
+			t.Fatalf("migrate %s: %v", command, err)
+		}
+		return out.String()
+	}
+	version := func() int64 {
+		t.Helper()
+		v, err := provider.GetDBVersion(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	if out := run("status"); strings.Count(out, "pending") != 3 {
+		t.Fatalf("expected 3 pending migrations, got:\n%s", out)
+	}
+
+	run("up")
+	if v := version(); v != 3 {
+		t.Fatalf("expected version 3 after up, got %d", v)
+	}
+	if out := run("up"); !strings.Contains(out, "no migrations to apply") {
+		t.Fatalf("expected nothing to apply, got:\n%s", out)
+	}
+
+	if out := run("redo"); !strings.Contains(out, "down") || !strings.Contains(out, "003_create_refresh_tokens.sql") {
+		t.Fatalf("expected the latest migration to be redone, got:\n%s", out)
+	}
+	if v := version(); v != 3 {
+		t.Fatalf("expected version 3 after redo, got %d", v)
+	}
+
+	run("down")
+	if v := version(); v != 2 {
+		t.Fatalf("expected version 2 after down, got %d", v)
+	}
+	if out := run("status"); strings.Count(out, "pending") != 1 {
+		t.Fatalf("expected 1 pending migration, got:\n%s", out)
+	}
+
+	if err := migrations.Run(ctx, provider, "sideways", &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an unknown command to fail")
+	}
+}
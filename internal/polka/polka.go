@@ -0,0 +1,56 @@
+package polka
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+)
+
+// SignatureHeader carries "t=<unix timestamp>,v1=<hex hmac>" where the HMAC
+// is SHA-256 over "<timestamp>.<raw body>" keyed with the webhook secret,
+// the same scheme as Chirpy's own webhooks, so webhooks.Sign makes it.
+const SignatureHeader = "X-Polka-Signature"
+
+// VerifySignature checks header against body and rejects signatures whose
+// timestamp is further than tolerance from now, so captured requests can't
+// be replayed later.
+func VerifySignature(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("malformed webhook signature")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed webhook signature timestamp")
+	}
+	signedAt := time.Unix(unix, 0)
+	if now.Sub(signedAt) > tolerance || signedAt.Sub(now) > tolerance {
+		return fmt.Errorf("webhook signature timestamp outside tolerance")
+	}
+
+	_, expected, _ := strings.Cut(webhooks.Sign(secret, signedAt, body), "v1=")
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid webhook signature")
+}
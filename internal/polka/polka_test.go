@@ -0,0 +1,50 @@
+package polka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := "secret"
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Now()
+
+	header := webhooks.Sign(secret, now, body)
+	err := VerifySignature(secret, header, body, now, time.Minute*5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestVerifySignatureRejectsTamperedBody(t *testing.T) {
+	secret := "secret"
+	now := time.Now()
+
+	header := webhooks.Sign(secret, now, []byte(`{"event":"user.upgraded"}`))
+	err := VerifySignature(secret, header, []byte(`{"event":"user.downgraded"}`), now, time.Minute*5)
+	if err == nil {
+		t.Fatalf("expected error for tampered body")
+	}
+}
+
+func TestVerifySignatureRejectsReplay(t *testing.T) {
+	secret := "secret"
+	body := []byte(`{"event":"user.upgraded"}`)
+	signedAt := time.Now().Add(-time.Hour)
+
+	header := webhooks.Sign(secret, signedAt, body)
+	err := VerifySignature(secret, header, body, time.Now(), time.Minute*5)
+	if err == nil {
+		t.Fatalf("expected error for stale signature")
+	}
+}
+
+func TestVerifySignatureRejectsMalformedHeader(t *testing.T) {
+	err := VerifySignature("secret", "v1=abc", []byte("{}"), time.Now(), time.Minute*5)
+	if err == nil {
+		t.Fatalf("expected error for header without timestamp")
+	}
+}
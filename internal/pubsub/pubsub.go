@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"sync"
+)
+
+// recentEvents is how many published event IDs a Broker remembers in order
+// to drop duplicates.
+const recentEvents = 1024
+
+// Event is a message fanned out to subscribers. IDs are assigned by the
+// publisher and must be unique; a Broker delivers each ID at most once.
+// Events with a RecipientID are private to that user.
+type Event struct {
+	ID          int64
+	Type        string
+	AuthorID    string
+	RecipientID string
+	Hashtags    []string
+	Data        []byte
+}
+
+// Subscription receives published events on C. C is closed when the
+// subscription is cancelled or when the subscriber falls so far behind that
+// its buffer fills up; a closed subscription never receives again.
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	closed bool
+}
+
+// Broker is an in-process pub/sub hub. It is safe for concurrent use.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	seen   map[int64]struct{}
+	recent []int64
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: map[*Subscription]struct{}{},
+		seen: map[int64]struct{}{},
+	}
+}
+
+// Subscribe registers a subscriber that can fall behind by up to buffer
+// events before it is dropped.
+func (b *Broker) Subscribe(buffer int) *Subscription {
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe cancels sub and closes its channel. It is safe to call more
+// than once.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(sub)
+}
+
+// Publish delivers e to every subscriber without blocking. It reports false
+// when an event with the same ID was already published, which happens when
+// an instance receives its own events back from another transport.
+func (b *Broker) Publish(e Event) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.seen[e.ID]; ok {
+		return false
+	}
+	b.seen[e.ID] = struct{}{}
+	b.recent = append(b.recent, e.ID)
+	if len(b.recent) > recentEvents {
+		delete(b.seen, b.recent[0])
+		b.recent = b.recent[1:]
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			b.drop(sub)
+		}
+	}
+	return true
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+func (b *Broker) drop(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subs, sub)
+	close(sub.ch)
+}
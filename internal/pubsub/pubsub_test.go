@@ -0,0 +1,57 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(4)
+
+	if !b.Publish(Event{ID: 1, Type: "chirp.created"}) {
+		t.Fatalf("expected first event to be published")
+	}
+	if b.Publish(Event{ID: 1, Type: "chirp.created"}) {
+		t.Fatalf("expected duplicate event to be dropped")
+	}
+	b.Publish(Event{ID: 2, Type: "chirp.deleted"})
+
+	for _, want := range []int64{1, 2} {
+		e := <-sub.C
+		if e.ID != want {
+			t.Fatalf("expected event %d, got %d", want, e.ID)
+		}
+	}
+	select {
+	case e := <-sub.C:
+		t.Fatalf("unexpected event %d", e.ID)
+	default:
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := NewBroker()
+	slow := b.Subscribe(1)
+	fast := b.Subscribe(8)
+
+	for i := int64(1); i <= 3; i++ {
+		b.Publish(Event{ID: i})
+		<-fast.C
+	}
+
+	if b.Subscribers() != 1 {
+		t.Fatalf("expected only the fast subscriber to remain, got %d", b.Subscribers())
+	}
+	if e, ok := <-slow.C; !ok || e.ID != 1 {
+		t.Fatalf("expected buffered event before close")
+	}
+	if _, ok := <-slow.C; ok {
+		t.Fatalf("expected slow subscription to be closed")
+	}
+
+	b.Unsubscribe(slow)
+	b.Unsubscribe(fast)
+	if _, ok := <-fast.C; ok {
+		t.Fatalf("expected unsubscribed channel to be closed")
+	}
+}
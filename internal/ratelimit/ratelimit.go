@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// Limiter counts events per key in fixed windows. It is safe for concurrent
+// use and only limits within a single process.
+type Limiter struct {
+	mu      sync.Mutex
+	windows map[string]*window
+	now     func() time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{
+		windows: map[string]*window{},
+		now:     time.Now,
+	}
+}
+
+// Allow records an event for key and reports whether it is within limit
+// events per period. A limit below one never allows anything.
+func (l *Limiter) Allow(key string, limit int, period time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= period {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// Prune drops windows older than period so idle keys don't accumulate.
+func (l *Limiter) Prune(period time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= period {
+			delete(l.windows, key)
+		}
+	}
+}
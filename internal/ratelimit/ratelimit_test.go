@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Now()
+	l := New()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow("user1", 3, time.Minute) {
+			t.Fatalf("expected event %d to be allowed", i+1)
+		}
+	}
+	if l.Allow("user1", 3, time.Minute) {
+		t.Fatalf("expected fourth event to be limited")
+	}
+	if !l.Allow("user2", 3, time.Minute) {
+		t.Fatalf("expected other keys to be unaffected")
+	}
+
+	now = now.Add(time.Minute)
+	if !l.Allow("user1", 3, time.Minute) {
+		t.Fatalf("expected a new window to allow events again")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	l := New()
+	l.now = func() time.Time { return now }
+
+	l.Allow("user1", 1, time.Minute)
+	now = now.Add(time.Minute * 2)
+	l.Prune(time.Minute)
+
+	if len(l.windows) != 0 {
+		t.Fatalf("expected stale windows to be pruned, got %d", len(l.windows))
+	}
+}
@@ -0,0 +1,160 @@
+package response
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gaba-bouliva/Chirpy/internal/logging"
+)
+
+// RequestIDHeader carries the request id in both directions: a client or
+// proxy may set it, and every response echoes the id that was used.
+const RequestIDHeader = "X-Request-ID"
+
+// Error is an API error as clients see it. Handlers return or pass these to
+// Err; any other error is treated as an internal error.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// WithDetails returns a copy of e carrying machine-readable details, such as
+// the list of invalid fields.
+func (e *Error) WithDetails(details any) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, "bad_request", message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, "unauthorized", message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, "forbidden", message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, "not_found", message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, "conflict", message)
+}
+
+func PayloadTooLarge(message string) *Error {
+	return New(http.StatusRequestEntityTooLarge, "payload_too_large", message)
+}
+
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, "rate_limited", message)
+}
+
+func BadGateway(message string) *Error {
+	return New(http.StatusBadGateway, "bad_gateway", message)
+}
+
+func Internal() *Error {
+	return New(http.StatusInternalServerError, "internal_error", "server encountered an error")
+}
+
+type errorBody struct {
+	*Error
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type envelope struct {
+	Error errorBody `json:"error"`
+}
+
+// Err writes err as a JSON error envelope:
+//
+//	{"error": {"code": "...", "message": "...", "details": ..., "request_id": "..."}}
+//
+// Errors that aren't an *Error are logged and reported as internal errors so
+// their text never reaches clients.
+func Err(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		logging.FromContext(r.Context(), slog.Default()).Error("request failed", "err", err)
+		apiErr = Internal()
+	}
+
+	JSON(w, apiErr.Status, envelope{Error: errorBody{
+		Error:     apiErr,
+		RequestID: RequestID(r.Context()),
+	}})
+}
+
+// JSON writes v as a JSON response with the given status.
+func JSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("encoding JSON response", "err", err)
+		status = http.StatusInternalServerError
+		body = []byte(`{"error":{"code":"internal_error","message":"server encountered an error"}}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the id WithRequestID assigned to the request, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID gives every request an id, reusing a well-formed
+// X-Request-ID from the client, and echoes it in the response.
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
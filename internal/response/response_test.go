@@ -0,0 +1,89 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErr(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{"api error", NotFound("chirp not found"), http.StatusNotFound, "not_found", "chirp not found"},
+		{"wrapped api error", errors.Join(errors.New("context"), Conflict("handle taken")), http.StatusConflict, "conflict", "handle taken"},
+		{"internal error", errors.New("pq: connection refused"), http.StatusInternalServerError, "internal_error", "server encountered an error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Err(w, r, tt.err)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, "req-123")
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("unexpected content type %q", ct)
+			}
+
+			var body struct {
+				Error struct {
+					Code      string `json:"code"`
+					Message   string `json:"message"`
+					RequestID string `json:"request_id"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("expected JSON body, got %q", rec.Body.String())
+			}
+			if body.Error.Code != tt.wantCode || body.Error.Message != tt.wantMsg {
+				t.Fatalf("unexpected error %+v", body.Error)
+			}
+			if body.Error.RequestID != "req-123" {
+				t.Fatalf("expected request id to be echoed, got %q", body.Error.RequestID)
+			}
+		})
+	}
+}
+
+func TestWithRequestIDGeneratesIDs(t *testing.T) {
+	var seen string
+	handler := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id with spaces")
+	handler.ServeHTTP(rec, req)
+
+	if seen == "" || seen == "bad id with spaces" {
+		t.Fatalf("expected a generated request id, got %q", seen)
+	}
+	if rec.Header().Get(RequestIDHeader) != seen {
+		t.Fatalf("expected response header to carry the request id")
+	}
+}
+
+func TestWithDetails(t *testing.T) {
+	base := BadRequest("invalid profile")
+	detailed := base.WithDetails(map[string]string{"website": "must be an http(s) URL"})
+	if base.Details != nil {
+		t.Fatalf("expected WithDetails not to modify the original error")
+	}
+	if detailed.Details == nil || detailed.Status != http.StatusBadRequest {
+		t.Fatalf("unexpected error %+v", detailed)
+	}
+}
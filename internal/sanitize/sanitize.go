@@ -0,0 +1,133 @@
+// Package sanitize cleans HTML received from other servers before it's
+// stored, keeping an allowlist of formatting tags and dropping everything
+// else.
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowed are the tags kept in sanitized HTML. They are written back
+// without attributes, except for links.
+var allowed = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Br:         true,
+	atom.A:          true,
+	atom.Span:       true,
+	atom.Strong:     true,
+	atom.Em:         true,
+	atom.B:          true,
+	atom.I:          true,
+	atom.Ul:         true,
+	atom.Ol:         true,
+	atom.Li:         true,
+	atom.Blockquote: true,
+	atom.Code:       true,
+	atom.Pre:        true,
+}
+
+// dropped are the tags whose content is removed along with the tag.
+var dropped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Template: true,
+	atom.Noscript: true,
+	atom.Textarea: true,
+	atom.Title:    true,
+}
+
+// HTML returns s with every tag not on the allowlist removed. The text
+// inside removed tags is kept, except for tags such as script and style
+// whose content isn't meant to be read. Links keep only http and https
+// hrefs and are marked nofollow.
+func HTML(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	// open counts the allowed tags written and not yet closed, so stray end
+	// tags are dropped and unclosed ones are closed at the end
+	open := map[atom.Atom]int{}
+	var stack []atom.Atom
+	skip := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// io.EOF, or input the tokenizer gave up on; either way the
+			// output so far is well formed
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if dropped[tok.DataAtom] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 || !allowed[tok.DataAtom] {
+				continue
+			}
+			writeStart(&b, tok)
+			if tok.DataAtom != atom.Br && tt == html.StartTagToken {
+				open[tok.DataAtom]++
+				stack = append(stack, tok.DataAtom)
+			}
+		case html.EndTagToken:
+			if dropped[tok.DataAtom] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 || open[tok.DataAtom] == 0 {
+				continue
+			}
+			// close anything opened inside this tag that wasn't closed
+			for len(stack) > 0 {
+				a := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				open[a]--
+				b.WriteString("</" + a.String() + ">")
+				if a == tok.DataAtom {
+					break
+				}
+			}
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteString("</" + stack[i].String() + ">")
+	}
+	return b.String()
+}
+
+func writeStart(b *strings.Builder, tok html.Token) {
+	b.WriteString("<" + tok.DataAtom.String())
+	if tok.DataAtom == atom.A {
+		for _, attr := range tok.Attr {
+			if attr.Namespace == "" && attr.Key == "href" && safeURL(attr.Val) {
+				b.WriteString(` href="` + html.EscapeString(attr.Val) + `"`)
+				break
+			}
+		}
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteString(">")
+}
+
+func safeURL(s string) bool {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
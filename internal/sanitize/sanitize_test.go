@@ -0,0 +1,29 @@
+package sanitize
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"<p>hello <strong>world</strong></p>", "<p>hello <strong>world</strong></p>"},
+		{"plain & simple", "plain &amp; simple"},
+		{`<p onclick="steal()" class="x">hi</p>`, "<p>hi</p>"},
+		{"<script>alert(1)</script>safe", "safe"},
+		{"<style>body{}</style><p>ok</p>", "<p>ok</p>"},
+		{"<div><img src=x onerror=alert(1)>text</div>", "text"},
+		{`<a href="https://example.com/a?b=1&c=2" target="_blank">link</a>`, `<a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">link</a>`},
+		{`<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"<p>line<br/>break</p>", "<p>line<br>break</p>"},
+		{"<p><em>unclosed", "<p><em>unclosed</em></p>"},
+		{"</p>stray<b>bold</p></b>", "stray<b>bold</b>"},
+		{"<p><em>mis</p>nested</em>", "<p><em>mis</em></p>nested"},
+		{"<svg><script>x</script></svg>y", "y"},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.in); got != tt.want {
+			t.Errorf("HTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
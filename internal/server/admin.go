@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+func (s *Server) middlewareMetricsInc(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fileserverHits.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) countHits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	htmlRes := fmt.Sprintf(`
+	<html>
+	  <body>
+	   <h1>Welcome, Chirpy Admin</h1>
+	   <p>Chirpy has been visited %d times!</p>
+	 </body>
+	</html>`, s.fileserverHits.Load())
+	w.Write([]byte(htmlRes))
+}
+
+func (s *Server) resetMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if s.env != "dev" {
+		response.Err(w, r, response.Forbidden("you can't perfom this action in current environment"))
+		return
+	}
+	s.fileserverHits.Swap(0)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Hits: %d", s.fileserverHits.Load())))
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+)
+
+type chirpAuthor struct {
+	ID          string `json:"id"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+}
+
+type chirpsResponseBody struct {
+	ID        string      `json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Body      string      `json:"body"`
+	UserId    string      `json:"user_id"`
+	Author    chirpAuthor `json:"author"`
+}
+
+func (s *Server) handleGetChirpByID(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if len(id) < 1 {
+		response.Err(w, r, response.BadRequest("invalid id provided"))
+		return
+	}
+	chirp, err := s.store.GetChirpById(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("chirp not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	chirpData := newChirpsResponseBody(chirp.Chirp, chirp.Handle, chirp.DisplayName)
+
+	response.JSON(w, http.StatusOK, chirpData)
+}
+
+func (s *Server) handleGetAllChirps(w http.ResponseWriter, r *http.Request) {
+	var chirpListData = []chirpsResponseBody{}
+	userId := r.URL.Query().Get("author_id")
+
+	if len(userId) > 0 {
+		chirpList, err := s.store.GetAllUserChirps(r.Context(), userId)
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+		for _, chirp := range chirpList {
+			chirpListData = append(chirpListData, newChirpsResponseBody(chirp.Chirp, chirp.Handle, chirp.DisplayName))
+		}
+	} else {
+		chirpList, err := s.store.GetAllChirps(r.Context())
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+		for _, chirp := range chirpList {
+			chirpListData = append(chirpListData, newChirpsResponseBody(chirp.Chirp, chirp.Handle, chirp.DisplayName))
+		}
+	}
+
+	sortArg := r.URL.Query().Get("sort")
+
+	if sortArg == "desc" {
+		sort.Slice(chirpListData, func(i, j int) bool { return chirpListData[i].CreatedAt.After(chirpListData[j].CreatedAt) })
+	}
+
+	response.JSON(w, http.StatusOK, chirpListData)
+
+}
+
+func newChirpsResponseBody(chirp database.Chirp, handle, displayName string) chirpsResponseBody {
+	return chirpsResponseBody{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserId:    chirp.UserID,
+		Author: chirpAuthor{
+			ID:          chirp.UserID,
+			Handle:      handle,
+			DisplayName: displayName,
+		},
+	}
+}
+
+func (s *Server) handleChirp(w http.ResponseWriter, r *http.Request) {
+	type ReqBody struct {
+		Body         string `json:"body"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh"`
+	}
+	reqParams := ReqBody{}
+
+	jsonDecoder := json.NewDecoder(r.Body)
+	err := jsonDecoder.Decode(&reqParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "invalid param(s) provided"))
+		return
+	}
+
+	user := currentUser(r)
+
+	caps, err := s.capabilitiesFor(r.Context(), user)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	if !s.rateLimiter.Allow("chirps:"+user.ID, caps.ChirpsPerMinute, time.Minute) {
+		response.Err(w, r, response.TooManyRequests("too many chirps, please slow down"))
+		return
+	}
+
+	validChirpBody, err := validateChirpBody(reqParams.Body, caps.MaxChirpLength)
+	if err != nil {
+		response.Err(w, r, response.BadRequest("invalid chirp body provided"))
+		return
+	}
+
+	createChirpParams := database.CreateChirpParams{
+		ID:        s.ids.NewID(),
+		CreatedAt: s.clock.Now(),
+		UpdatedAt: s.clock.Now(),
+		Body:      validChirpBody,
+		UserID:    user.ID,
+	}
+
+	createdChirp, err := s.store.CreateChirp(r.Context(), createChirpParams)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	s.metrics.chirpsCreated.Inc()
+
+	jsonData := newChirpsResponseBody(createdChirp, user.Handle, user.DisplayName)
+	s.publishChirpCreated(r.Context(), jsonData)
+	s.publishStreamEvent(r.Context(), webhooks.EventChirpCreated, user.ID, createdChirp.Body, jsonData)
+	s.federateChirpCreated(r.Context(), jsonData)
+
+	response.JSON(w, http.StatusCreated, jsonData)
+}
+
+func (s *Server) handleDeleteChirp(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	chirpId := r.PathValue("id")
+
+	chirp, err := s.store.GetChirpById(r.Context(), chirpId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("chirp not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	if chirp.Chirp.UserID != userId {
+		response.Err(w, r, response.Forbidden("Forbidden you're not the owner of the chirp"))
+		return
+	}
+
+	err = s.store.DeleteChirpById(r.Context(), chirp.Chirp.ID)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	deletedChirp := map[string]string{
+		"id":      chirp.Chirp.ID,
+		"user_id": chirp.Chirp.UserID,
+	}
+	s.publishEvent(r.Context(), webhooks.EventChirpDeleted, "", deletedChirp)
+	s.publishStreamEvent(r.Context(), webhooks.EventChirpDeleted, chirp.Chirp.UserID, chirp.Chirp.Body, deletedChirp)
+	s.federateChirpDeleted(r.Context(), chirp.Chirp.UserID, chirp.Chirp.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateChirpBody checks that chirp is at most maxLength characters and
+// masks banned words.
+func validateChirpBody(chirp string, maxLength int) (string, error) {
+	if utf8.RuneCountInString(chirp) > maxLength {
+		return "", fmt.Errorf("chirp is too long")
+	}
+
+	unWantedWords := map[string]string{
+		"kerfuffle": "****",
+		"sharbert":  "****",
+		"fornax":    "****",
+	}
+
+	reqBodyWords := strings.Split(chirp, " ")
+	for i, word := range reqBodyWords {
+		if hiddenWord, exists := unWantedWords[strings.ToLower(word)]; exists {
+			reqBodyWords[i] = hiddenWord
+		}
+	}
+
+	return strings.Join(reqBodyWords, " "), nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/entitlements"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+// capabilitiesFor is the single place handlers resolve what a user's plan
+// allows.
+func (s *Server) capabilitiesFor(ctx context.Context, user database.User) (entitlements.Capabilities, error) {
+	if !user.IsChirpyRed {
+		return entitlements.ForPlan(entitlements.PlanFree), nil
+	}
+	if s.db == nil {
+		return entitlements.ForPlan(entitlements.PlanChirpyRed), nil
+	}
+
+	sub, err := s.db.GetLatestUserSubscription(ctx, user.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entitlements.ForPlan(entitlements.PlanChirpyRed), nil
+		}
+		return entitlements.Capabilities{}, err
+	}
+	return entitlements.ForPlan(sub.Plan), nil
+}
+
+// runRateLimitPruner keeps the in-memory rate limiter from growing with
+// users who stopped posting.
+func (s *Server) runRateLimitPruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.rateLimiter.Prune(interval)
+		}
+	}
+}
+
+func (s *Server) handleEditChirp(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Body string `json:"body"`
+	}
+
+	user := currentUser(r)
+
+	var reqBodyParams reqBody
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error proccessing request"))
+		return
+	}
+
+	chirp, err := s.store.GetChirpById(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("chirp not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	if chirp.Chirp.UserID != user.ID {
+		response.Err(w, r, response.Forbidden("Forbidden you're not the owner of the chirp"))
+		return
+	}
+
+	caps, err := s.capabilitiesFor(r.Context(), user)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	if !caps.CanEdit(chirp.Chirp.CreatedAt, s.clock.Now()) {
+		response.Err(w, r, response.Forbidden("chirp can no longer be edited"))
+		return
+	}
+
+	validChirpBody, err := validateChirpBody(reqBodyParams.Body, caps.MaxChirpLength)
+	if err != nil {
+		response.Err(w, r, response.BadRequest("invalid chirp body provided"))
+		return
+	}
+
+	updatedChirp, err := s.store.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		Body:      validChirpBody,
+		UpdatedAt: s.clock.Now(),
+		ID:        chirp.Chirp.ID,
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	res := newChirpsResponseBody(updatedChirp, chirp.Handle, chirp.DisplayName)
+	s.federateChirpUpdated(r.Context(), res)
+
+	response.JSON(w, http.StatusOK, res)
+}
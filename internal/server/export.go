@@ -0,0 +1,530 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+const (
+	exportStatusPending = "pending"
+	exportStatusReady   = "ready"
+	exportStatusFailed  = "failed"
+)
+
+// exportLinkTTL is how long the emailed download link works. Exports are
+// deleted once it has expired.
+const exportLinkTTL = time.Hour * 24
+
+// exportExpiryBatch is how many expired exports are deleted per query.
+const exportExpiryBatch = 100
+
+type exportResponseBody struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type exportFollow struct {
+	FollowerID string    `json:"follower_id"`
+	FolloweeID string    `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type exportBillingEvent struct {
+	ID          string          `json:"id"`
+	Event       string          `json:"event"`
+	Status      string          `json:"status"`
+	Payload     json.RawMessage `json:"payload"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+type exportNotification struct {
+	ID            string     `json:"id"`
+	Type          string     `json:"type"`
+	ActorID       *string    `json:"actor_id"`
+	RemoteActorID *string    `json:"remote_actor_id"`
+	ChirpID       *string    `json:"chirp_id"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type exportNotificationPreference struct {
+	Type      string    `json:"type"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type exportWebhookDelivery struct {
+	EndpointID string `json:"endpoint_id"`
+	webhookDeliveryResponseBody
+}
+
+type exportRemoteFollow struct {
+	ActorID   string    `json:"actor_id"`
+	Accepted  *bool     `json:"accepted,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportRemoteLike struct {
+	ChirpID   string    `json:"chirp_id"`
+	ActorID   string    `json:"actor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// exportFile is one file of the export archive and the tables it's read
+// from.
+type exportFile struct {
+	name   string
+	tables []string
+	data   func(s *Server, ctx context.Context, user database.User) (any, error)
+}
+
+// userExportFiles lists everything Chirpy stores about a user. Every table
+// in the schema is either read by one of these files or listed, with the
+// reason, in userExportSkippedTables.
+var userExportFiles = []exportFile{
+	{"profile.json", []string{"users"}, (*Server).exportProfile},
+	{"chirps.json", []string{"chirps"}, (*Server).exportChirps},
+	{"sessions.json", []string{"refresh_tokens"}, (*Server).exportSessions},
+	{"follows.json", []string{"follows"}, (*Server).exportFollows},
+	{"subscriptions.json", []string{"subscriptions"}, (*Server).exportSubscriptions},
+	{"billing_events.json", []string{"webhook_events"}, (*Server).exportBillingEvents},
+	{"notifications.json", []string{"notifications"}, (*Server).exportNotifications},
+	{"notification_preferences.json", []string{"notification_preferences"}, (*Server).exportNotificationPreferences},
+	{"webhooks.json", []string{"webhook_endpoints"}, (*Server).exportWebhooks},
+	{"webhook_deliveries.json", []string{"webhook_deliveries", "webhook_delivery_attempts"}, (*Server).exportWebhookDeliveries},
+	{"remote_followers.json", []string{"remote_followers"}, (*Server).exportRemoteFollowers},
+	{"remote_following.json", []string{"remote_following"}, (*Server).exportRemoteFollowing},
+	{"remote_likes.json", []string{"remote_likes"}, (*Server).exportRemoteLikes},
+}
+
+// userExportSkippedTables are the tables left out of exports, and why.
+var userExportSkippedTables = map[string]string{
+	"user_exports":          "the exports themselves",
+	"user_suggestions":      "recomputed from follows and hashtags",
+	"user_suggestion_runs":  "bookkeeping for the suggestions job",
+	"stream_events":         "short-lived copies of chirps and notifications for live streams",
+	"actor_keys":            "the federation signing key is a credential",
+	"remote_actors":         "accounts on other servers, referenced by id from the remote files",
+	"remote_notes":          "posts by accounts on other servers",
+	"federation_deliveries": "queued copies of chirps sent to other servers",
+}
+
+func (s *Server) handleExportUser(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+
+	createExportParams := database.CreateUserExportParams{
+		ID:        s.ids.NewID(),
+		UserID:    user.ID,
+		Status:    exportStatusPending,
+		CreatedAt: s.clock.Now(),
+		UpdatedAt: s.clock.Now(),
+	}
+
+	export, err := s.db.CreateUserExport(r.Context(), createExportParams)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	// the archive is built after the response is sent, so it must not
+	// depend on the request context
+	s.goWorker(func() { s.buildUserExport(context.Background(), export.ID, user) })
+
+	response.JSON(w, http.StatusAccepted, exportResponseBody{
+		ID:        export.ID,
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt,
+	})
+}
+
+func (s *Server) buildUserExport(ctx context.Context, exportId string, user database.User) {
+	blobKey := fmt.Sprintf("exports/%s.zip", exportId)
+
+	err := s.writeUserExport(ctx, blobKey, user)
+	if err != nil {
+		s.log(ctx).Error("export failed", "export_id", exportId, "err", err)
+		err = s.db.UpdateUserExportStatus(ctx, database.UpdateUserExportStatusParams{
+			Status:    exportStatusFailed,
+			UpdatedAt: s.clock.Now(),
+			ID:        exportId,
+		})
+		if err != nil {
+			s.log(ctx).Error("export failed", "export_id", exportId, "err", err)
+		}
+		return
+	}
+
+	err = s.db.UpdateUserExportStatus(ctx, database.UpdateUserExportStatusParams{
+		Status:    exportStatusReady,
+		BlobKey:   sql.NullString{String: blobKey, Valid: true},
+		UpdatedAt: s.clock.Now(),
+		ID:        exportId,
+	})
+	if err != nil {
+		s.log(ctx).Error("export failed", "export_id", exportId, "err", err)
+		return
+	}
+
+	expiresAt := s.clock.Now().Add(exportLinkTTL)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", auth.MakeDownloadSignature(exportId, expiresAt, s.tokenSecret))
+	link := fmt.Sprintf("%s/api/exports/%s?%s", s.baseURL, exportId, query.Encode())
+
+	body := fmt.Sprintf("Your Chirpy data export is ready.\n\nDownload it here (the link expires in 24 hours):\n%s\n", link)
+	err = s.mailer.Send(ctx, user.Email, "Your Chirpy data export", body)
+	if err != nil {
+		s.log(ctx).Error("export failed", "export_id", exportId, "err", err)
+	}
+}
+
+// writeUserExport zips everything Chirpy stores about the user and saves the
+// archive in the blob store under blobKey.
+func (s *Server) writeUserExport(ctx context.Context, blobKey string, user database.User) error {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range userExportFiles {
+		data, err := file.data(s, ctx, user)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", file.name, err)
+		}
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		return err
+	}
+
+	return s.blobs.Put(ctx, blobKey, &buf)
+}
+
+func (s *Server) exportProfile(ctx context.Context, user database.User) (any, error) {
+	return newUsersResponseBody(user), nil
+}
+
+func (s *Server) exportChirps(ctx context.Context, user database.User) (any, error) {
+	chirps, err := s.store.GetAllUserChirps(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []chirpsResponseBody{}
+	for _, chirp := range chirps {
+		results = append(results, newChirpsResponseBody(chirp.Chirp, chirp.Handle, chirp.DisplayName))
+	}
+	return results, nil
+}
+
+func (s *Server) exportSessions(ctx context.Context, user database.User) (any, error) {
+	tokens, err := s.store.GetAllUserTokens(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	// refresh token values are credentials, so only their metadata is exported
+	results := []exportSession{}
+	for _, token := range tokens {
+		session := exportSession{
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if token.RevokedAt.Valid {
+			session.RevokedAt = &token.RevokedAt.Time
+		}
+		results = append(results, session)
+	}
+	return results, nil
+}
+
+func (s *Server) exportFollows(ctx context.Context, user database.User) (any, error) {
+	follows, err := s.db.GetUserFollows(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []exportFollow{}
+	for _, follow := range follows {
+		results = append(results, exportFollow(follow))
+	}
+	return results, nil
+}
+
+func (s *Server) exportSubscriptions(ctx context.Context, user database.User) (any, error) {
+	subs, err := s.db.GetUserSubscriptions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []subscriptionResponseBody{}
+	for _, sub := range subs {
+		results = append(results, newSubscriptionResponseBody(sub))
+	}
+	return results, nil
+}
+
+func (s *Server) exportBillingEvents(ctx context.Context, user database.User) (any, error) {
+	events, err := s.db.GetUserWebhookEvents(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []exportBillingEvent{}
+	for _, event := range events {
+		res := exportBillingEvent{
+			ID:         event.ID,
+			Event:      event.Event,
+			Status:     event.Status,
+			Payload:    json.RawMessage(event.Payload),
+			ReceivedAt: event.ReceivedAt,
+		}
+		if event.ProcessedAt.Valid {
+			res.ProcessedAt = &event.ProcessedAt.Time
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (s *Server) exportNotifications(ctx context.Context, user database.User) (any, error) {
+	notifications, err := s.db.GetUserNotifications(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []exportNotification{}
+	for _, n := range notifications {
+		res := exportNotification{
+			ID:        n.ID,
+			Type:      n.Type,
+			CreatedAt: n.CreatedAt,
+		}
+		if n.ActorID.Valid {
+			res.ActorID = &n.ActorID.String
+		}
+		if n.RemoteActorID.Valid {
+			res.RemoteActorID = &n.RemoteActorID.String
+		}
+		if n.ChirpID.Valid {
+			res.ChirpID = &n.ChirpID.String
+		}
+		if n.ReadAt.Valid {
+			res.ReadAt = &n.ReadAt.Time
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (s *Server) exportNotificationPreferences(ctx context.Context, user database.User) (any, error) {
+	prefs, err := s.db.GetNotificationPreferences(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []exportNotificationPreference{}
+	for _, pref := range prefs {
+		results = append(results, exportNotificationPreference{
+			Type:      pref.Type,
+			Enabled:   pref.Enabled,
+			UpdatedAt: pref.UpdatedAt,
+		})
+	}
+	return results, nil
+}
+
+func (s *Server) exportWebhooks(ctx context.Context, user database.User) (any, error) {
+	endpoints, err := s.db.GetUserWebhookEndpoints(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	// signing secrets are credentials, and newWebhookEndpointResponseBody
+	// leaves them out
+	results := []webhookEndpointResponseBody{}
+	for _, endpoint := range endpoints {
+		results = append(results, newWebhookEndpointResponseBody(endpoint))
+	}
+	return results, nil
+}
+
+func (s *Server) exportWebhookDeliveries(ctx context.Context, user database.User) (any, error) {
+	deliveries, err := s.db.GetUserWebhookDeliveries(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []exportWebhookDelivery{}
+	for _, delivery := range deliveries {
+		attempts, err := s.db.GetWebhookDeliveryAttempts(ctx, delivery.ID)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, exportWebhookDelivery{
+			EndpointID:                  delivery.EndpointID,
+			webhookDeliveryResponseBody: newWebhookDeliveryResponseBody(delivery, attempts),
+		})
+	}
+	return results, nil
+}
+
+func (s *Server) exportRemoteFollowers(ctx context.Context, user database.User) (any, error) {
+	followers, err := s.db.GetUserRemoteFollowers(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []exportRemoteFollow{}
+	for _, follower := range followers {
+		results = append(results, exportRemoteFollow{ActorID: follower.ActorID, CreatedAt: follower.CreatedAt})
+	}
+	return results, nil
+}
+
+func (s *Server) exportRemoteFollowing(ctx context.Context, user database.User) (any, error) {
+	following, err := s.db.GetUserRemoteFollowing(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []exportRemoteFollow{}
+	for _, follow := range following {
+		results = append(results, exportRemoteFollow{ActorID: follow.ActorID, Accepted: &follow.Accepted, CreatedAt: follow.CreatedAt})
+	}
+	return results, nil
+}
+
+func (s *Server) exportRemoteLikes(ctx context.Context, user database.User) (any, error) {
+	likes, err := s.db.GetUserRemoteLikes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	results := []exportRemoteLike{}
+	for _, like := range likes {
+		results = append(results, exportRemoteLike{ChirpID: like.ChirpID, ActorID: like.ActorID, CreatedAt: like.CreatedAt})
+	}
+	return results, nil
+}
+
+func (s *Server) handleDownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportId := r.PathValue("id")
+
+	expiresUnix, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		response.Err(w, r, response.BadRequest("invalid download link"))
+		return
+	}
+
+	err = auth.ValidateDownloadSignature(exportId, time.Unix(expiresUnix, 0), s.clock.Now(), r.URL.Query().Get("signature"), s.tokenSecret)
+	if err != nil {
+		response.Err(w, r, response.Forbidden(err.Error()))
+		return
+	}
+
+	export, err := s.db.GetUserExport(r.Context(), exportId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("export not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	if export.Status != exportStatusReady || !export.BlobKey.Valid {
+		response.Err(w, r, response.NotFound("export not found"))
+		return
+	}
+
+	rc, err := s.blobs.Get(r.Context(), export.BlobKey.String)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, export.ID))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		s.log(r.Context()).Warn("sending export", "export_id", export.ID, "err", err)
+	}
+}
+
+// runExportExpiryJob deletes exports, archive and row, whose download link
+// has expired.
+func (s *Server) runExportExpiryJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.deleteExpiredExports(ctx)
+		if err != nil {
+			s.log(ctx).Error("export expiry job failed", "err", err)
+		} else if deleted > 0 {
+			s.log(ctx).Info("export expiry job deleted exports", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) deleteExpiredExports(ctx context.Context) (int, error) {
+	deleted := 0
+	for {
+		exports, err := s.db.GetExpiredUserExports(ctx, database.GetExpiredUserExportsParams{
+			UpdatedAt: s.clock.Now().Add(-exportLinkTTL),
+			Limit:     exportExpiryBatch,
+		})
+		if err != nil {
+			return deleted, err
+		}
+		for _, export := range exports {
+			err = s.deleteUserExport(ctx, export)
+			if err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if len(exports) < exportExpiryBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// deleteUserExport removes the archive before the row, so a failure leaves
+// the row behind for the next attempt instead of an orphaned blob.
+func (s *Server) deleteUserExport(ctx context.Context, export database.UserExport) error {
+	if export.BlobKey.Valid {
+		err := s.blobs.Delete(ctx, export.BlobKey.String)
+		if err != nil {
+			return err
+		}
+	}
+	return s.db.DeleteUserExport(ctx, export.ID)
+}
@@ -0,0 +1,972 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/activitypub"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/netguard"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/gaba-bouliva/Chirpy/internal/sanitize"
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+)
+
+// remoteActorTTL is how long a fetched remote actor is trusted before it is
+// fetched again.
+const remoteActorTTL = time.Hour * 24
+
+// outboxPageSize is the number of activities per outbox page.
+const outboxPageSize = 20
+
+var errInvalidActivity = errors.New("invalid activity")
+
+// Reactions to local chirps, pushed to live timelines.
+const (
+	eventChirpLiked   = "chirp.liked"
+	eventChirpUnliked = "chirp.unliked"
+)
+
+func (s *Server) actorURL(userId string) string {
+	return s.baseURL + "/ap/users/" + userId
+}
+
+func (s *Server) noteURL(chirpId string) string {
+	return s.baseURL + "/ap/chirps/" + chirpId
+}
+
+// localID returns the id at the end of a local ActivityPub URL with the
+// given path prefix, or "" if uri isn't one of ours.
+func (s *Server) localID(uri, prefix string) string {
+	id, ok := strings.CutPrefix(uri, s.baseURL+prefix)
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return ""
+	}
+	return id
+}
+
+// federationScheme is the scheme used to reach other instances. Plain HTTP
+// is only allowed in dev so two local instances can federate.
+func (s *Server) federationScheme() string {
+	if s.env == "dev" {
+		return "http"
+	}
+	return "https"
+}
+
+// newOutboundClient returns the client for URLs supplied by users and
+// remote servers. It can't reach private or loopback addresses, except in
+// dev so two local instances can federate.
+func (s *Server) newOutboundClient() *http.Client {
+	if s.env == "dev" {
+		return &http.Client{Timeout: time.Second * 10}
+	}
+	return netguard.NewClient(time.Second * 10)
+}
+
+// remoteURL parses uri, an address on another instance, and checks that it
+// uses federationScheme (or https, in dev).
+func (s *Server) remoteURL(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != s.federationScheme()) {
+		return nil, fmt.Errorf("%q is not an %s URL", uri, s.federationScheme())
+	}
+	return u, nil
+}
+
+// sameHost reports whether the remote addresses a and b are on the same
+// server.
+func (s *Server) sameHost(a, b string) bool {
+	ua, err := s.remoteURL(a)
+	if err != nil {
+		return false
+	}
+	ub, err := s.remoteURL(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host)
+}
+
+func writeActivityJSON(w http.ResponseWriter, r *http.Request, v any) {
+	jsonRes, err := json.Marshal(v)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", activitypub.ContentType)
+	w.Write(jsonRes)
+}
+
+// actorKey returns the user's signing key pair, generating it the first time
+// the user is federated.
+func (s *Server) actorKey(ctx context.Context, userId string) (database.ActorKey, error) {
+	key, err := s.db.GetActorKey(ctx, userId)
+	if err != sql.ErrNoRows {
+		return key, err
+	}
+
+	publicPEM, privatePEM, err := activitypub.GenerateKey()
+	if err != nil {
+		return key, err
+	}
+	err = s.db.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:        userId,
+		PublicKeyPem:  publicPEM,
+		PrivateKeyPem: privatePEM,
+		CreatedAt:     s.clock.Now(),
+	})
+	if err != nil {
+		return key, err
+	}
+	// another request may have created a key first; use whichever won
+	return s.db.GetActorKey(ctx, userId)
+}
+
+// federatedUser loads a local user by id for the ActivityPub endpoints,
+// treating users pending deletion as gone.
+func (s *Server) federatedUser(ctx context.Context, userId string) (database.User, error) {
+	user, err := s.store.GetUserById(ctx, userId)
+	if err == nil && user.DeleteAfter.Valid {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, err
+}
+
+func (s *Server) handleWebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	account, ok := strings.CutPrefix(resource, "acct:")
+	handle, host, _ := strings.Cut(account, "@")
+
+	instance, err := url.Parse(s.baseURL)
+	if !ok || err != nil || !strings.EqualFold(host, instance.Host) {
+		response.Err(w, r, response.NotFound("resource not found"))
+		return
+	}
+
+	user, err := s.store.GetUserByHandle(r.Context(), strings.ToLower(handle))
+	if err != nil || user.DeleteAfter.Valid {
+		if err == nil || err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("resource not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	jsonRes, err := json.Marshal(activitypub.WebFinger{
+		Subject: "acct:" + user.Handle + "@" + instance.Host,
+		Aliases: []string{s.actorURL(user.ID)},
+		Links: []activitypub.WebFingerLink{
+			{Rel: "self", Type: activitypub.ContentType, Href: s.actorURL(user.ID)},
+		},
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jrd+json")
+	w.Write(jsonRes)
+}
+
+func (s *Server) handleGetActor(w http.ResponseWriter, r *http.Request) {
+	user, err := s.federatedUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("user not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	key, err := s.actorKey(r.Context(), user.ID)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	actorURL := s.actorURL(user.ID)
+	writeActivityJSON(w, r, activitypub.Actor{
+		Context:           []string{activitypub.Context, activitypub.SecurityContext},
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: user.Handle,
+		Name:              user.DisplayName,
+		Summary:           html.EscapeString(user.Bio),
+		URL:               s.baseURL + "/api/users/" + user.Handle,
+		Inbox:             actorURL + "/inbox",
+		Outbox:            actorURL + "/outbox",
+		Followers:         actorURL + "/followers",
+		Endpoints:         &activitypub.Endpoints{SharedInbox: s.baseURL + "/ap/inbox"},
+		PublicKey: activitypub.PublicKey{
+			ID:           actorURL + "#main-key",
+			Owner:        actorURL,
+			PublicKeyPem: key.PublicKeyPem,
+		},
+	})
+}
+
+func (s *Server) newNote(chirp chirpsResponseBody) activitypub.Note {
+	note := activitypub.Note{
+		ID:           s.noteURL(chirp.ID),
+		Type:         "Note",
+		AttributedTo: s.actorURL(chirp.UserId),
+		Content:      "<p>" + html.EscapeString(chirp.Body) + "</p>",
+		URL:          s.baseURL + "/api/chirps/" + chirp.ID,
+		Published:    &chirp.CreatedAt,
+		To:           []string{activitypub.PublicCollection},
+		Cc:           []string{s.actorURL(chirp.UserId) + "/followers"},
+	}
+	if chirp.UpdatedAt.After(chirp.CreatedAt) {
+		note.Updated = &chirp.UpdatedAt
+	}
+	return note
+}
+
+func (s *Server) newCreateActivity(chirp chirpsResponseBody) activitypub.Activity {
+	note := s.newNote(chirp)
+	return activitypub.Activity{
+		ID:        note.ID + "/activity",
+		Type:      "Create",
+		Actor:     note.AttributedTo,
+		Object:    activitypub.NewObject(note),
+		Published: note.Published,
+		To:        note.To,
+		Cc:        note.Cc,
+	}
+}
+
+func (s *Server) handleGetNote(w http.ResponseWriter, r *http.Request) {
+	chirp, err := s.store.GetChirpById(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("chirp not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	note := s.newNote(newChirpsResponseBody(chirp.Chirp, chirp.Handle, chirp.DisplayName))
+	note.Context = activitypub.Context
+	writeActivityJSON(w, r, note)
+}
+
+// handleGetOutbox serves the outbox collection, or with ?page= one page of
+// it, newest first.
+func (s *Server) handleGetOutbox(w http.ResponseWriter, r *http.Request) {
+	user, err := s.federatedUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("user not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	chirpList, err := s.store.GetAllUserChirps(r.Context(), user.ID)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	outboxURL := s.actorURL(user.ID) + "/outbox"
+	lastPage := max((len(chirpList)+outboxPageSize-1)/outboxPageSize, 1)
+	pageURL := func(n int) string {
+		return outboxURL + "?page=" + strconv.Itoa(n)
+	}
+
+	pageStr := r.URL.Query().Get("page")
+	if pageStr == "" {
+		writeActivityJSON(w, r, activitypub.OrderedCollection{
+			Context:    activitypub.Context,
+			ID:         outboxURL,
+			Type:       "OrderedCollection",
+			TotalItems: len(chirpList),
+			First:      pageURL(1),
+			Last:       pageURL(lastPage),
+		})
+		return
+	}
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 || page > lastPage {
+		response.Err(w, r, response.NotFound("page not found"))
+		return
+	}
+
+	collectionPage := activitypub.OrderedCollectionPage{
+		Context:      activitypub.Context,
+		ID:           pageURL(page),
+		Type:         "OrderedCollectionPage",
+		PartOf:       outboxURL,
+		OrderedItems: []activitypub.Activity{},
+	}
+	if page > 1 {
+		collectionPage.Prev = pageURL(page - 1)
+	}
+	if page < lastPage {
+		collectionPage.Next = pageURL(page + 1)
+	}
+
+	// chirps are oldest first, so count pages back from the end
+	end := len(chirpList) - (page-1)*outboxPageSize
+	start := max(end-outboxPageSize, 0)
+	for i := end - 1; i >= start; i-- {
+		chirp := chirpList[i]
+		collectionPage.OrderedItems = append(collectionPage.OrderedItems,
+			s.newCreateActivity(newChirpsResponseBody(chirp.Chirp, chirp.Handle, chirp.DisplayName)))
+	}
+
+	writeActivityJSON(w, r, collectionPage)
+}
+
+// handleGetFollowers only publishes the follower count, not who they are.
+func (s *Server) handleGetFollowers(w http.ResponseWriter, r *http.Request) {
+	user, err := s.federatedUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("user not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	stats, err := s.db.GetUserProfileStats(r.Context(), user.ID)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+	remoteFollowers, err := s.db.CountRemoteFollowers(r.Context(), user.ID)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	writeActivityJSON(w, r, activitypub.OrderedCollection{
+		Context:    activitypub.Context,
+		ID:         s.actorURL(user.ID) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: int(stats.FollowerCount + remoteFollowers),
+	})
+}
+
+// remoteActor returns a remote actor from the cache, fetching it when it
+// isn't cached, is older than remoteActorTTL, or refresh is set.
+func (s *Server) remoteActor(ctx context.Context, uri string, refresh bool) (database.RemoteActor, error) {
+	if !refresh {
+		cached, err := s.db.GetRemoteActor(ctx, uri)
+		if err == nil && time.Since(cached.FetchedAt) < remoteActorTTL {
+			return cached, nil
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return cached, err
+		}
+	}
+
+	if _, err := s.remoteURL(uri); err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	var actor activitypub.Actor
+	err := activitypub.Fetch(ctx, s.federationClient, uri, &actor)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+	if actor.ID != uri || actor.PublicKey.PublicKeyPem == "" {
+		return database.RemoteActor{}, fmt.Errorf("%s is not a usable actor", uri)
+	}
+	if _, err := s.remoteURL(actor.Inbox); err != nil {
+		return database.RemoteActor{}, fmt.Errorf("%s has an unusable inbox: %w", uri, err)
+	}
+
+	sharedInbox := ""
+	if actor.Endpoints != nil {
+		if _, err := s.remoteURL(actor.Endpoints.SharedInbox); err == nil {
+			sharedInbox = actor.Endpoints.SharedInbox
+		}
+	}
+	name := actor.Name
+	if name == "" {
+		name = actor.PreferredUsername
+	}
+
+	return s.db.UpsertRemoteActor(ctx, database.UpsertRemoteActorParams{
+		ID:                actor.ID,
+		PreferredUsername: actor.PreferredUsername,
+		Name:              name,
+		Url:               actor.URL,
+		Inbox:             actor.Inbox,
+		SharedInbox:       sharedInbox,
+		PublicKeyID:       actor.PublicKey.ID,
+		PublicKeyPem:      actor.PublicKey.PublicKeyPem,
+		FetchedAt:         s.clock.Now(),
+	})
+}
+
+// verifyInboxRequest checks the request's HTTP Signature and returns the
+// actor that signed it. A failed check against a cached key is retried once
+// with a freshly fetched actor in case the key was rotated. Keys are only
+// fetched from the host of actorId, the actor the activity claims to be
+// from, so a forged keyId can't send the server anywhere else.
+func (s *Server) verifyInboxRequest(r *http.Request, body []byte, actorId string) (database.RemoteActor, error) {
+	keyId, err := activitypub.SignatureKeyID(r)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+	actorURI, _, _ := strings.Cut(keyId, "#")
+
+	keyURL, err := s.remoteURL(actorURI)
+	if err != nil {
+		return database.RemoteActor{}, activitypub.ErrInvalidSignature
+	}
+	actorURL, err := s.remoteURL(actorId)
+	if err != nil || !strings.EqualFold(keyURL.Host, actorURL.Host) {
+		return database.RemoteActor{}, activitypub.ErrInvalidSignature
+	}
+
+	for _, refresh := range []bool{false, true} {
+		actor, err := s.remoteActor(r.Context(), actorURI, refresh)
+		if err != nil {
+			return actor, err
+		}
+		if actor.PublicKeyID != keyId {
+			continue
+		}
+		key, err := activitypub.ParsePublicKey(actor.PublicKeyPem)
+		if err != nil {
+			return actor, err
+		}
+		if err = activitypub.VerifyRequest(r, body, key, s.clock.Now(), time.Minute*5); err == nil {
+			return actor, nil
+		}
+	}
+	return database.RemoteActor{}, activitypub.ErrInvalidSignature
+}
+
+func (s *Server) handleInbox(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error reading request body"))
+		return
+	}
+
+	var activity activitypub.Activity
+	err = json.Unmarshal(body, &activity)
+	if err != nil || activity.Type == "" || activity.Actor == "" {
+		response.Err(w, r, response.BadRequest("invalid activity"))
+		return
+	}
+
+	actor, err := s.verifyInboxRequest(r, body, activity.Actor)
+	if err != nil {
+		// deleted accounts can't be fetched to check their signature, and
+		// there's nothing of theirs we'd keep without having seen them
+		if activity.Type == "Delete" && activitypub.ObjectID(activity.Object) == activity.Actor {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		response.Err(w, r, response.Unauthorized("invalid signature"))
+		return
+	}
+	if actor.ID != activity.Actor {
+		response.Err(w, r, response.Unauthorized("activity actor does not match signature"))
+		return
+	}
+
+	err = s.processActivity(r.Context(), actor, activity, body)
+	if err != nil {
+		if errors.Is(err, errInvalidActivity) {
+			response.Err(w, r, response.BadRequest(err.Error()))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// processActivity applies an inbox activity whose signature has been
+// verified. Activity types Chirpy doesn't use are ignored.
+func (s *Server) processActivity(ctx context.Context, actor database.RemoteActor, activity activitypub.Activity, raw []byte) error {
+	objectId := activitypub.ObjectID(activity.Object)
+
+	switch activity.Type {
+	case "Follow":
+		userId := s.localID(objectId, "/ap/users/")
+		if _, err := s.federatedUser(ctx, userId); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: no such user", errInvalidActivity)
+			}
+			return err
+		}
+		err := s.db.CreateRemoteFollower(ctx, database.CreateRemoteFollowerParams{
+			UserID:     userId,
+			ActorID:    actor.ID,
+			ActivityID: activity.ID,
+			CreatedAt:  s.clock.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		return s.enqueueFederationDelivery(ctx, userId, actor.Inbox, activitypub.Activity{
+			ID:     s.actorURL(userId) + "#accepts/" + s.ids.NewID(),
+			Type:   "Accept",
+			Actor:  s.actorURL(userId),
+			Object: json.RawMessage(raw),
+		})
+
+	case "Accept":
+		_, err := s.db.AcceptRemoteFollowing(ctx, database.AcceptRemoteFollowingParams{
+			ActivityID: objectId,
+			ActorID:    actor.ID,
+		})
+		return err
+
+	case "Undo":
+		var inner activitypub.Activity
+		if err := json.Unmarshal(activity.Object, &inner); err != nil {
+			// only the id was sent; likes are the only thing undone by id
+			return s.unlikeChirp(ctx, actor, objectId)
+		}
+		switch inner.Type {
+		case "Follow":
+			return s.db.DeleteRemoteFollower(ctx, database.DeleteRemoteFollowerParams{
+				UserID:  s.localID(activitypub.ObjectID(inner.Object), "/ap/users/"),
+				ActorID: actor.ID,
+			})
+		case "Like":
+			return s.unlikeChirp(ctx, actor, inner.ID)
+		}
+		return nil
+
+	case "Create", "Update":
+		var note activitypub.Note
+		if err := json.Unmarshal(activity.Object, &note); err != nil || note.ID == "" {
+			return fmt.Errorf("%w: object is not embedded", errInvalidActivity)
+		}
+		if note.Type != "Note" {
+			return nil
+		}
+		if note.AttributedTo != "" && note.AttributedTo != actor.ID {
+			return fmt.Errorf("%w: note is attributed to someone else", errInvalidActivity)
+		}
+		// a note's id is its address, so it must live on the signer's own
+		// server; otherwise one actor could overwrite another's notes
+		if !s.sameHost(note.ID, actor.ID) {
+			return fmt.Errorf("%w: note is hosted elsewhere", errInvalidActivity)
+		}
+		// only keep notes someone here asked to see
+		followed, err := s.db.IsRemoteActorFollowed(ctx, actor.ID)
+		if err != nil || !followed {
+			return err
+		}
+		published := s.clock.Now()
+		if note.Published != nil {
+			published = *note.Published
+		}
+		return s.db.CreateRemoteNote(ctx, database.CreateRemoteNoteParams{
+			ID:        note.ID,
+			ActorID:   actor.ID,
+			Content:   sanitize.HTML(note.Content),
+			Url:       note.URL,
+			Published: published,
+			CreatedAt: s.clock.Now(),
+		})
+
+	case "Delete":
+		if objectId == actor.ID {
+			return s.db.DeleteRemoteActor(ctx, actor.ID)
+		}
+		return s.db.DeleteRemoteNote(ctx, database.DeleteRemoteNoteParams{
+			ID:      objectId,
+			ActorID: actor.ID,
+		})
+
+	case "Like":
+		chirp, err := s.store.GetChirpById(ctx, s.localID(objectId, "/ap/chirps/"))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: no such chirp", errInvalidActivity)
+			}
+			return err
+		}
+		inserted, err := s.db.CreateRemoteLike(ctx, database.CreateRemoteLikeParams{
+			ChirpID:    chirp.Chirp.ID,
+			ActorID:    actor.ID,
+			ActivityID: activity.ID,
+			CreatedAt:  s.clock.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		// a redelivered or repeated like isn't news
+		if inserted {
+			s.publishReaction(ctx, eventChirpLiked, chirp.Chirp.UserID, chirp.Chirp.ID, actor)
+			s.notify(ctx, newNotification{
+				userId:        chirp.Chirp.UserID,
+				kind:          notificationLike,
+				remoteActorId: actor.ID,
+				chirpId:       chirp.Chirp.ID,
+			})
+		}
+		return nil
+	}
+	return nil
+}
+
+// unlikeChirp removes the like activityId by actor and tells the chirp's
+// audience about it.
+func (s *Server) unlikeChirp(ctx context.Context, actor database.RemoteActor, activityId string) error {
+	chirpIds, err := s.db.DeleteRemoteLike(ctx, database.DeleteRemoteLikeParams{
+		ActivityID: activityId,
+		ActorID:    actor.ID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, chirpId := range chirpIds {
+		chirp, err := s.store.GetChirpById(ctx, chirpId)
+		if err != nil {
+			// the chirp was deleted meanwhile; there's no one to tell
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return err
+		}
+		s.publishReaction(ctx, eventChirpUnliked, chirp.Chirp.UserID, chirp.Chirp.ID, actor)
+	}
+	return nil
+}
+
+// publishReaction pushes a reaction to a chirp by authorId to the live
+// timelines that show it.
+func (s *Server) publishReaction(ctx context.Context, event, authorId, chirpId string, actor database.RemoteActor) {
+	s.publishStreamEvent(ctx, event, authorId, "", reactionResponseBody{
+		ChirpID: chirpId,
+		Actor: remoteActorResponseBody{
+			ID:       actor.ID,
+			Username: actor.PreferredUsername,
+			Name:     actor.Name,
+			URL:      actor.Url,
+		},
+	})
+}
+
+// enqueueFederationDelivery queues a signed delivery of activity from
+// userId to inbox.
+func (s *Server) enqueueFederationDelivery(ctx context.Context, userId, inbox string, activity activitypub.Activity) error {
+	activity.Context = activitypub.Context
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+
+	return s.db.CreateFederationDelivery(ctx, database.CreateFederationDeliveryParams{
+		ID:            s.ids.NewID(),
+		UserID:        userId,
+		Inbox:         inbox,
+		Activity:      string(payload),
+		Status:        deliveryStatusPending,
+		NextAttemptAt: s.clock.Now(),
+		CreatedAt:     s.clock.Now(),
+	})
+}
+
+// federate sends activity to every remote follower of userId, once per
+// inbox. Failures are logged rather than failing the request.
+func (s *Server) federate(ctx context.Context, userId string, activity activitypub.Activity) {
+	if s.db == nil {
+		return
+	}
+
+	inboxes, err := s.db.GetRemoteFollowerInboxes(ctx, userId)
+	if err != nil {
+		s.log(ctx).Error("federate failed", "activity", activity.Type, "err", err)
+		return
+	}
+
+	for _, inbox := range inboxes {
+		err = s.enqueueFederationDelivery(ctx, userId, inbox, activity)
+		if err != nil {
+			s.log(ctx).Error("federate failed", "activity", activity.Type, "inbox", inbox, "err", err)
+		}
+	}
+}
+
+func (s *Server) federateChirpCreated(ctx context.Context, chirp chirpsResponseBody) {
+	s.federate(ctx, chirp.UserId, s.newCreateActivity(chirp))
+}
+
+func (s *Server) federateChirpUpdated(ctx context.Context, chirp chirpsResponseBody) {
+	note := s.newNote(chirp)
+	s.federate(ctx, chirp.UserId, activitypub.Activity{
+		ID:     note.ID + "#updates/" + strconv.FormatInt(chirp.UpdatedAt.Unix(), 10),
+		Type:   "Update",
+		Actor:  note.AttributedTo,
+		Object: activitypub.NewObject(note),
+		To:     note.To,
+		Cc:     note.Cc,
+	})
+}
+
+func (s *Server) federateChirpDeleted(ctx context.Context, userId, chirpId string) {
+	s.federate(ctx, userId, activitypub.Activity{
+		ID:     s.noteURL(chirpId) + "#delete",
+		Type:   "Delete",
+		Actor:  s.actorURL(userId),
+		Object: activitypub.NewObject(activitypub.Object{ID: s.noteURL(chirpId), Type: "Tombstone"}),
+		To:     []string{activitypub.PublicCollection},
+	})
+}
+
+// runFederationWorker sends due deliveries to remote inboxes, leasing them
+// the same way runWebhookWorker does and retrying on the same schedule.
+func (s *Server) runFederationWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			now := s.clock.Now()
+			deliveries, err := s.db.ClaimDueFederationDeliveries(ctx, database.ClaimDueFederationDeliveriesParams{
+				LeaseUntil:    now.Add(deliveryLease),
+				Now:           now,
+				MaxDeliveries: 1,
+			})
+			if err != nil {
+				s.log(ctx).Error("federation worker failed", "err", err)
+			}
+			if len(deliveries) == 0 {
+				break
+			}
+			s.attemptFederationDelivery(ctx, deliveries[0])
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) deliverFederated(ctx context.Context, delivery database.FederationDelivery) error {
+	key, err := s.actorKey(ctx, delivery.UserID)
+	if err != nil {
+		return err
+	}
+	privateKey, err := activitypub.ParsePrivateKey(key.PrivateKeyPem)
+	if err != nil {
+		return err
+	}
+
+	_, err = activitypub.Deliver(ctx, s.federationClient, delivery.Inbox, []byte(delivery.Activity),
+		s.actorURL(delivery.UserID)+"#main-key", privateKey)
+	return err
+}
+
+func (s *Server) attemptFederationDelivery(ctx context.Context, delivery database.FederationDelivery) {
+	deliverErr := s.deliverFederated(ctx, delivery)
+
+	attempts := delivery.Attempts + 1
+	params := database.UpdateFederationDeliveryResultParams{
+		Status:        deliveryStatusSucceeded,
+		Attempts:      attempts,
+		NextAttemptAt: s.clock.Now(),
+		ID:            delivery.ID,
+	}
+	if deliverErr != nil {
+		params.Status = deliveryStatusPending
+		params.LastError = deliverErr.Error()
+		params.NextAttemptAt = s.clock.Now().Add(webhooks.Backoff(int(attempts)))
+		if attempts >= webhooks.MaxAttempts {
+			params.Status = deliveryStatusFailed
+		}
+	}
+	err := s.db.UpdateFederationDeliveryResult(ctx, params)
+	if err != nil {
+		s.log(ctx).Error("federation delivery failed", "delivery_id", delivery.ID, "err", err)
+	}
+}
+
+type remoteFollowResponseBody struct {
+	Actor    string `json:"actor"`
+	Username string `json:"username"`
+	Accepted bool   `json:"accepted"`
+}
+
+type remoteActorResponseBody struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+}
+
+type remoteNoteResponseBody struct {
+	ID        string                  `json:"id"`
+	URL       string                  `json:"url"`
+	Content   string                  `json:"content"`
+	Published time.Time               `json:"published"`
+	Actor     remoteActorResponseBody `json:"actor"`
+}
+
+// reactionResponseBody is the payload of chirp.liked and chirp.unliked.
+type reactionResponseBody struct {
+	ChirpID string                  `json:"chirp_id"`
+	Actor   remoteActorResponseBody `json:"actor"`
+}
+
+// lookupRemoteAccount resolves "user@host" to a remote actor. The actor is
+// always refetched since the user is about to act on it.
+func (s *Server) lookupRemoteAccount(ctx context.Context, account string) (database.RemoteActor, error) {
+	actorURL, err := activitypub.LookupAccount(ctx, s.federationClient, s.federationScheme(), account)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+	return s.remoteActor(ctx, actorURL, true)
+}
+
+func (s *Server) handleRemoteFollow(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Account string `json:"account"`
+	}
+
+	userId := currentUser(r).ID
+
+	var reqBodyParams reqBody
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error proccessing request"))
+		return
+	}
+
+	actor, err := s.lookupRemoteAccount(r.Context(), reqBodyParams.Account)
+	if err != nil {
+		response.Err(w, r, response.BadGateway("could not find remote account"))
+		return
+	}
+
+	follow := activitypub.Activity{
+		ID:     s.actorURL(userId) + "#follows/" + s.ids.NewID(),
+		Type:   "Follow",
+		Actor:  s.actorURL(userId),
+		Object: activitypub.NewObject(actor.ID),
+	}
+	err = s.db.CreateRemoteFollowing(r.Context(), database.CreateRemoteFollowingParams{
+		UserID:     userId,
+		ActorID:    actor.ID,
+		ActivityID: follow.ID,
+		CreatedAt:  s.clock.Now(),
+	})
+	if err == nil {
+		err = s.enqueueFederationDelivery(r.Context(), userId, actor.Inbox, follow)
+	}
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	// the follow counts once the remote server sends Accept
+	response.JSON(w, http.StatusAccepted, remoteFollowResponseBody{
+		Actor:    actor.ID,
+		Username: actor.PreferredUsername,
+		Accepted: false,
+	})
+}
+
+func (s *Server) handleRemoteUnfollow(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	actor, err := s.lookupRemoteAccount(r.Context(), r.URL.Query().Get("account"))
+	if err != nil {
+		response.Err(w, r, response.BadGateway("could not find remote account"))
+		return
+	}
+
+	following, err := s.db.GetRemoteFollowing(r.Context(), database.GetRemoteFollowingParams{
+		UserID:  userId,
+		ActorID: actor.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("not following this account"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	err = s.enqueueFederationDelivery(r.Context(), userId, actor.Inbox, activitypub.Activity{
+		ID:    following.ActivityID + "/undo",
+		Type:  "Undo",
+		Actor: s.actorURL(userId),
+		Object: activitypub.NewObject(activitypub.Activity{
+			ID:     following.ActivityID,
+			Type:   "Follow",
+			Actor:  s.actorURL(userId),
+			Object: activitypub.NewObject(actor.ID),
+		}),
+	})
+	if err == nil {
+		err = s.db.DeleteRemoteFollowing(r.Context(), database.DeleteRemoteFollowingParams{
+			UserID:  userId,
+			ActorID: actor.ID,
+		})
+	}
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetRemoteTimeline(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	notes, err := s.db.GetRemoteTimeline(r.Context(), database.GetRemoteTimelineParams{
+		UserID:   userId,
+		MaxNotes: int32(parseLimit(r, 20, 100)),
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	results := []remoteNoteResponseBody{}
+	for _, note := range notes {
+		// sanitized again for notes stored before content was sanitized on
+		// ingest
+		res := remoteNoteResponseBody{
+			ID:        note.RemoteNote.ID,
+			URL:       note.RemoteNote.Url,
+			Content:   sanitize.HTML(note.RemoteNote.Content),
+			Published: note.RemoteNote.Published,
+		}
+		res.Actor.ID = note.RemoteNote.ActorID
+		res.Actor.Username = note.PreferredUsername
+		res.Actor.Name = note.Name
+		res.Actor.URL = note.ActorUrl
+		results = append(results, res)
+	}
+
+	response.JSON(w, http.StatusOK, results)
+}
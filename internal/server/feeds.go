@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/feed"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+const (
+	feedFormatRSS  = "rss"
+	feedFormatAtom = "atom"
+)
+
+const feedPageSize = 20
+
+var hashtagNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// feedTitleLength is how much of a chirp is used as its entry title.
+const feedTitleLength = 60
+
+func feedEntryTitle(body string) string {
+	if utf8.RuneCountInString(body) <= feedTitleLength {
+		return body
+	}
+	return string([]rune(body)[:feedTitleLength-1]) + "…"
+}
+
+// serveFeed serves one page of f, newest chirps first. count and
+// lastUpdated describe every chirp in the feed; loadPage returns a page of
+// them, newest first.
+func (s *Server) serveFeed(w http.ResponseWriter, r *http.Request, f feed.Feed, format string, count int64, lastUpdated time.Time, loadPage func(limit, offset int32) ([]chirpsResponseBody, error)) {
+	page := 1
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		var err error
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			response.Err(w, r, response.NotFound("page not found"))
+			return
+		}
+	}
+
+	lastPage := max(int((count+feedPageSize-1)/feedPageSize), 1)
+	if page > lastPage {
+		response.Err(w, r, response.NotFound("page not found"))
+		return
+	}
+
+	chirps, err := loadPage(feedPageSize, int32((page-1)*feedPageSize))
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	if lastUpdated.After(f.Updated) {
+		f.Updated = lastUpdated
+	}
+	for _, chirp := range chirps {
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:        "urn:uuid:" + chirp.ID,
+			Title:     feedEntryTitle(chirp.Body),
+			Link:      s.baseURL + "/api/chirps/" + chirp.ID,
+			Content:   chirp.Body,
+			Author:    chirp.Author.Handle,
+			Published: chirp.CreatedAt,
+			Updated:   chirp.UpdatedAt,
+		})
+	}
+
+	pageURL := func(n int) string {
+		return fmt.Sprintf("%s%s?page=%d", s.baseURL, r.URL.Path, n)
+	}
+	f.Links = feed.Links{
+		Self:  pageURL(page),
+		First: pageURL(1),
+		Last:  pageURL(lastPage),
+	}
+	if page > 1 {
+		f.Links.Prev = pageURL(page - 1)
+	}
+	if page < lastPage {
+		f.Links.Next = pageURL(page + 1)
+	}
+
+	writeFeed(w, r, f, format)
+}
+
+// writeFeed renders f and serves it with an ETag of its contents and a
+// Last-Modified of its newest chirp, answering conditional requests with 304.
+func writeFeed(w http.ResponseWriter, r *http.Request, f feed.Feed, format string) {
+	var body []byte
+	var err error
+	if format == feedFormatAtom {
+		w.Header().Set("Content-Type", feed.AtomContentType)
+		body, err = f.Atom()
+	} else {
+		w.Header().Set("Content-Type", feed.RSSContentType)
+		body, err = f.RSS()
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		response.Err(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", f.Updated, bytes.NewReader(body))
+}
+
+func (s *Server) handleUserFeedRSS(w http.ResponseWriter, r *http.Request) {
+	s.serveUserFeed(w, r, feedFormatRSS)
+}
+
+func (s *Server) handleUserFeedAtom(w http.ResponseWriter, r *http.Request) {
+	s.serveUserFeed(w, r, feedFormatAtom)
+}
+
+func (s *Server) serveUserFeed(w http.ResponseWriter, r *http.Request, format string) {
+	user, err := s.store.GetUserById(r.Context(), r.PathValue("id"))
+	if err != nil || user.DeleteAfter.Valid {
+		if err == nil || err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("user not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	summary, err := s.store.GetUserChirpsSummary(r.Context(), user.ID)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	title := "@" + user.Handle + " on Chirpy"
+	if user.DisplayName != "" {
+		title = user.DisplayName + " (@" + user.Handle + ") on Chirpy"
+	}
+
+	f := feed.Feed{
+		ID:          s.baseURL + "/users/" + user.ID,
+		Title:       title,
+		Description: user.Bio,
+		Link:        s.baseURL + "/api/users/" + user.Handle,
+		Updated:     user.CreatedAt,
+	}
+	s.serveFeed(w, r, f, format, summary.ChirpCount, summary.LastUpdated, func(limit, offset int32) ([]chirpsResponseBody, error) {
+		rows, err := s.store.GetUserChirpsPage(r.Context(), database.GetUserChirpsPageParams{
+			UserID: user.ID,
+			Limit:  limit,
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chirps := []chirpsResponseBody{}
+		for _, row := range rows {
+			chirps = append(chirps, newChirpsResponseBody(row.Chirp, row.Handle, row.DisplayName))
+		}
+		return chirps, nil
+	})
+}
+
+func (s *Server) handleHashtagFeedRSS(w http.ResponseWriter, r *http.Request) {
+	s.serveHashtagFeed(w, r, feedFormatRSS)
+}
+
+func (s *Server) handleHashtagFeedAtom(w http.ResponseWriter, r *http.Request) {
+	s.serveHashtagFeed(w, r, feedFormatAtom)
+}
+
+func (s *Server) serveHashtagFeed(w http.ResponseWriter, r *http.Request, format string) {
+	hashtag := strings.ToLower(strings.TrimPrefix(r.PathValue("hashtag"), "#"))
+	if !hashtagNamePattern.MatchString(hashtag) {
+		response.Err(w, r, response.BadRequest("invalid hashtag"))
+		return
+	}
+
+	summary, err := s.store.GetHashtagChirpsSummary(r.Context(), hashtag)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	f := feed.Feed{
+		ID:          s.baseURL + "/hashtags/" + hashtag,
+		Title:       "#" + hashtag + " on Chirpy",
+		Description: "Chirps tagged #" + hashtag,
+		Link:        s.baseURL + "/hashtags/" + hashtag + "/feed." + format,
+		Updated:     time.Unix(0, 0),
+	}
+	s.serveFeed(w, r, f, format, summary.ChirpCount, summary.LastUpdated, func(limit, offset int32) ([]chirpsResponseBody, error) {
+		rows, err := s.store.GetHashtagChirpsPage(r.Context(), database.GetHashtagChirpsPageParams{
+			Hashtag: hashtag,
+			Limit:   limit,
+			Offset:  offset,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chirps := []chirpsResponseBody{}
+		for _, row := range rows {
+			chirps = append(chirps, newChirpsResponseBody(row.Chirp, row.Handle, row.DisplayName))
+		}
+		return chirps, nil
+	})
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/logging"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+// log returns the request's logger when ctx belongs to a request, and the
+// server's logger otherwise.
+func (s *Server) log(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, s.logger)
+}
+
+// requestInfo collects what the access log reports about a request that is
+// only known once handlers have run.
+type requestInfo struct {
+	userId string
+}
+
+type requestInfoKey struct{}
+
+// withRequestUser records the authenticated user in the access log and in
+// the logger used for the rest of the request.
+func (s *Server) withRequestUser(ctx context.Context, userId string) context.Context {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userId = userId
+	}
+	return logging.NewContext(ctx, s.log(ctx).With("user_id", userId))
+}
+
+// accessLog gives each request a logger carrying its request id and logs
+// the request once it has been served.
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		logger := s.logger.With("request_id", response.RequestID(r.Context()))
+
+		ctx := context.WithValue(r.Context(), requestInfoKey{}, info)
+		r = r.WithContext(logging.NewContext(ctx, logger))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		latency := time.Since(start)
+		s.metrics.observeRequest(r.Pattern, rec.statusCode(), latency)
+
+		level := slog.LevelInfo
+		if rec.status >= 500 {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", r.Pattern),
+			slog.Int("status", rec.statusCode()),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("latency", latency),
+		}
+		if info.userId != "" {
+			attrs = append(attrs, slog.String("user_id", info.userId))
+		}
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// statusRecorder remembers the status and size of a response. It keeps
+// the Flusher and Hijacker of the writer it wraps, which event streams and
+// WebSockets need.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
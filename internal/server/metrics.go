@@ -0,0 +1,165 @@
+package server
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the server's Prometheus collectors. Each server has its own
+// registry so that servers created in tests don't collide.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+
+	chirpsCreated     prometheus.Counter
+	logins            *prometheus.CounterVec
+	polkaWebhooks     *prometheus.CounterVec
+	webhookDeliveries *prometheus.CounterVec
+}
+
+// poolStats is implemented by stores backed by a database/sql pool.
+type poolStats interface {
+	Stats() sql.DBStats
+}
+
+func newMetrics(s *Server) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests served, by route and status code.",
+		}, []string{"route", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts, by result (succeeded or failed).",
+		}, []string{"result"}),
+		polkaWebhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_polka_webhooks_total",
+			Help: "Polka webhooks received, by outcome.",
+		}, []string{"outcome"}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_delivery_attempts_total",
+			Help: "Outbound webhook delivery attempts, by resulting delivery status.",
+		}, []string{"status"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.latency,
+		m.chirpsCreated,
+		m.logins,
+		m.polkaWebhooks,
+		m.webhookDeliveries,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "chirpy_fileserver_hits",
+			Help: "Requests for static files under /app/ since the last reset.",
+		}, func() float64 { return float64(s.fileserverHits.Load()) }),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if pool, ok := s.store.(poolStats); ok {
+		m.registry.MustRegister(newPoolCollector(pool))
+	}
+	return m
+}
+
+// observeRequest records a served request. Requests that matched no route
+// share one label so that scanners can't grow the series without bound.
+func (m *metrics) observeRequest(route string, status int, latency time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	m.requests.WithLabelValues(route, strconv.Itoa(status)).Inc()
+	m.latency.WithLabelValues(route).Observe(latency.Seconds())
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// requireMetricsToken keeps the metrics off the public internet: scrapers
+// must send the metrics token as a bearer token. Without one configured
+// they're only served in dev.
+func (s *Server) requireMetricsToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.metricsToken == "" {
+			if s.env != "dev" {
+				response.Err(w, r, response.Forbidden("metrics need METRICS_TOKEN outside the dev environment"))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(s.metricsToken)) != 1 {
+			response.Err(w, r, response.Unauthorized("invalid metrics token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// poolCollector reports the connection pool statistics of a database/sql
+// store.
+type poolCollector struct {
+	pool poolStats
+
+	maxOpen      *prometheus.Desc
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+func newPoolCollector(pool poolStats) *poolCollector {
+	return &poolCollector{
+		pool:         pool,
+		maxOpen:      prometheus.NewDesc("chirpy_db_max_open_connections", "Maximum number of open connections to the database.", nil, nil),
+		open:         prometheus.NewDesc("chirpy_db_open_connections", "Established connections, in use or idle.", nil, nil),
+		inUse:        prometheus.NewDesc("chirpy_db_in_use_connections", "Connections currently in use.", nil, nil),
+		idle:         prometheus.NewDesc("chirpy_db_idle_connections", "Idle connections.", nil, nil),
+		waitCount:    prometheus.NewDesc("chirpy_db_wait_count_total", "Connections waited for.", nil, nil),
+		waitDuration: prometheus.NewDesc("chirpy_db_wait_duration_seconds_total", "Time spent waiting for a connection.", nil, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.pool.Stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
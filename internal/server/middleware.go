@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+// principal is the signed-in user a request was authenticated as.
+type principal struct {
+	User database.User
+	// Token is the access token the request was authenticated with.
+	Token string
+}
+
+type principalKey struct{}
+
+// principalFrom returns the request's principal. It's always set for
+// handlers wrapped with requireAuth, and set for optionalAuth handlers
+// when the request carried a token.
+func principalFrom(ctx context.Context) (principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	return p, ok
+}
+
+// currentUser is for handlers wrapped with requireAuth.
+func currentUser(r *http.Request) database.User {
+	p, _ := principalFrom(r.Context())
+	return p.User
+}
+
+// requireAuth rejects requests without a valid access token before they
+// reach next.
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return s.authenticate(next, true)
+}
+
+// optionalAuth lets anonymous requests through, but a token that is sent
+// must be valid.
+func (s *Server) optionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return s.authenticate(next, false)
+}
+
+func (s *Server) authenticate(next http.HandlerFunc, required bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokenStr, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			if required {
+				response.Err(w, r, response.Unauthorized("authentication token not found"))
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		userId, err := auth.ValidateJWT(tokenStr, s.tokenSecret)
+		if err != nil {
+			response.Err(w, r, response.Unauthorized("invalid token provided"))
+			return
+		}
+
+		// tokens outlive accounts that have since been purged
+		user, err := s.store.GetUserById(r.Context(), userId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				response.Err(w, r, response.Unauthorized("invalid token provided"))
+				return
+			}
+			response.Err(w, r, err)
+			return
+		}
+		// access tokens outlive the revoked sessions of accounts scheduled
+		// for deletion; logging in again cancels it and issues new ones
+		if user.DeleteAfter.Valid {
+			response.Err(w, r, response.New(http.StatusUnauthorized, "pending_deletion", "account is scheduled for deletion, log in again to cancel it"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, principal{User: user, Token: tokenStr})
+		ctx = s.withRequestUser(ctx, user.ID)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// allowQueryToken accepts the access token as an access_token query
+// parameter, for clients such as browser WebSockets that can't set headers.
+// It must wrap the auth middleware.
+func allowQueryToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next(w, r)
+	}
+}
+
+// limitedBody is a request body capped at the server's default size that
+// remembers the uncapped body, so maxBody can apply a different limit.
+type limitedBody struct {
+	io.ReadCloser
+	original io.ReadCloser
+}
+
+// limitBodies caps every request body at s.maxBodyBytes unless the route
+// sets its own limit with maxBody.
+func (s *Server) limitBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, s.maxBodyBytes), original: r.Body}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxBody lets a route accept bodies of up to n bytes instead of the
+// server's default.
+func maxBody(n int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if body, ok := r.Body.(*limitedBody); ok {
+			r.Body = http.MaxBytesReader(w, body.original, n)
+		}
+		next(w, r)
+	}
+}
+
+// badBody is the error for a request body that couldn't be read or decoded.
+func badBody(err error, message string) *response.Error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return response.PayloadTooLarge(fmt.Sprintf("request body must not be larger than %d bytes", maxErr.Limit))
+	}
+	return response.BadRequest(message)
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+const (
+	notificationFollow  = "follow"
+	notificationMention = "mention"
+	notificationLike    = "like"
+)
+
+// notificationTypes lists every type a user can turn off.
+var notificationTypes = []string{notificationFollow, notificationMention, notificationLike}
+
+// notificationCreatedEvent is pushed to the recipient's live connections.
+const notificationCreatedEvent = "notification.created"
+
+// newNotification describes something that happened to userId because of
+// actorId, or remoteActorId for activities from other instances. chirpId is
+// set when a chirp is involved.
+type newNotification struct {
+	userId        string
+	kind          string
+	actorId       string
+	remoteActorId string
+	chirpId       string
+}
+
+const (
+	// notificationGroupIDs caps the notification ids listed per group;
+	// clients mark the rest read by group key.
+	notificationGroupIDs = 50
+	// notificationGroupActors caps the actor handles listed per group.
+	notificationGroupActors = 10
+)
+
+// groupKey decides which notifications are shown together: follows are
+// grouped by the UTC day they happened on, mentions and likes by chirp.
+func (n newNotification) groupKey(at time.Time) string {
+	if n.chirpId == "" {
+		return n.kind + ":" + at.UTC().Format(time.DateOnly)
+	}
+	return n.kind + ":" + n.chirpId
+}
+
+type notificationResponseBody struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Actor     chirpAuthor `json:"actor"`
+	ChirpID   *string     `json:"chirp_id"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type notificationGroupResponseBody struct {
+	GroupKey          string    `json:"group_key"`
+	Type              string    `json:"type"`
+	ChirpID           *string   `json:"chirp_id"`
+	Summary           string    `json:"summary"`
+	ActorCount        int64     `json:"actor_count"`
+	Actors            []string  `json:"actors"`
+	NotificationCount int64     `json:"notification_count"`
+	IDs               []string  `json:"ids"`
+	Read              bool      `json:"read"`
+	LatestAt          time.Time `json:"latest_at"`
+}
+
+type notificationsResponseBody struct {
+	UnreadCount int64                           `json:"unread_count"`
+	Groups      []notificationGroupResponseBody `json:"groups"`
+}
+
+// notify records a notification and pushes it to the recipient's live
+// connections, unless the recipient turned that type off or is the actor.
+// Failures are logged rather than failing the request that caused them.
+func (s *Server) notify(ctx context.Context, n newNotification) {
+	if s.db == nil || n.userId == n.actorId {
+		return
+	}
+
+	enabled, err := s.db.IsNotificationEnabled(ctx, database.IsNotificationEnabledParams{
+		UserID: n.userId,
+		Type:   n.kind,
+	})
+	if err != nil {
+		s.log(ctx).Error("notify failed", "kind", n.kind, "err", err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	actor, err := s.notificationActor(ctx, n)
+	if err != nil {
+		s.log(ctx).Error("notify failed", "kind", n.kind, "err", err)
+		return
+	}
+
+	notification, err := s.db.CreateNotification(ctx, database.CreateNotificationParams{
+		ID:            s.ids.NewID(),
+		UserID:        n.userId,
+		Type:          n.kind,
+		ActorID:       sql.NullString{String: n.actorId, Valid: n.remoteActorId == ""},
+		RemoteActorID: sql.NullString{String: n.remoteActorId, Valid: n.remoteActorId != ""},
+		ChirpID:       sql.NullString{String: n.chirpId, Valid: n.chirpId != ""},
+		GroupKey:      n.groupKey(s.clock.Now()),
+		CreatedAt:     s.clock.Now(),
+	})
+	if err != nil {
+		s.log(ctx).Error("notify failed", "kind", n.kind, "err", err)
+		return
+	}
+
+	res := notificationResponseBody{
+		ID:        notification.ID,
+		Type:      notification.Type,
+		Actor:     actor,
+		CreatedAt: notification.CreatedAt,
+	}
+	if notification.ChirpID.Valid {
+		res.ChirpID = &notification.ChirpID.String
+	}
+	s.publishUserEvent(ctx, notificationCreatedEvent, n.userId, actor.ID, res)
+}
+
+// notificationActor describes who caused n. Remote actors are shown by
+// their id and "user@host" handle.
+func (s *Server) notificationActor(ctx context.Context, n newNotification) (chirpAuthor, error) {
+	if n.remoteActorId != "" {
+		actor, err := s.db.GetRemoteActor(ctx, n.remoteActorId)
+		if err != nil {
+			return chirpAuthor{}, err
+		}
+		handle := actor.PreferredUsername
+		if u, err := url.Parse(actor.ID); err == nil {
+			handle += "@" + u.Host
+		}
+		return chirpAuthor{ID: actor.ID, Handle: handle, DisplayName: actor.Name}, nil
+	}
+
+	user, err := s.store.GetUserById(ctx, n.actorId)
+	if err != nil {
+		return chirpAuthor{}, err
+	}
+	return chirpAuthor{ID: user.ID, Handle: user.Handle, DisplayName: user.DisplayName}, nil
+}
+
+// notificationSummary renders a group as a sentence such as
+// "alice and 2 others followed you".
+func notificationSummary(kind string, actors []string, actorCount int64) string {
+	var who string
+	switch {
+	case len(actors) == 0:
+		who = "someone"
+	case actorCount == 1:
+		who = actors[0]
+	case actorCount == 2 && len(actors) >= 2:
+		who = actors[0] + " and " + actors[1]
+	case actorCount == 2:
+		who = actors[0] + " and 1 other"
+	default:
+		who = fmt.Sprintf("%s and %d others", actors[0], actorCount-1)
+	}
+
+	switch kind {
+	case notificationFollow:
+		return who + " followed you"
+	case notificationMention:
+		return who + " mentioned you in a chirp"
+	case notificationLike:
+		return who + " liked your chirp"
+	}
+	return who + " " + kind
+}
+
+func newNotificationGroupResponseBody(group database.GetNotificationGroupsRow) notificationGroupResponseBody {
+	// the same user may show up more than once, e.g. after re-following
+	seen := map[string]bool{}
+	actors := []string{}
+	for _, handle := range group.ActorHandles {
+		if !seen[handle] {
+			seen[handle] = true
+			actors = append(actors, handle)
+		}
+	}
+
+	res := notificationGroupResponseBody{
+		GroupKey:          group.GroupKey,
+		Type:              group.Type,
+		Summary:           notificationSummary(group.Type, actors, group.ActorCount),
+		ActorCount:        group.ActorCount,
+		Actors:            actors,
+		NotificationCount: group.NotificationCount,
+		IDs:               group.Ids,
+		Read:              group.Read,
+		LatestAt:          group.LatestAt,
+	}
+	if group.ChirpID.Valid {
+		res.ChirpID = &group.ChirpID.String
+	}
+	return res
+}
+
+func (s *Server) handleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	unread, err := s.db.GetUnreadNotificationCount(r.Context(), userId)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	groups, err := s.db.GetNotificationGroups(r.Context(), database.GetNotificationGroupsParams{
+		MaxIds:    notificationGroupIDs,
+		MaxActors: notificationGroupActors,
+		UserID:    userId,
+		MaxGroups: int32(parseLimit(r, 20, 100)),
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	res := notificationsResponseBody{
+		UnreadCount: unread,
+		Groups:      []notificationGroupResponseBody{},
+	}
+	for _, group := range groups {
+		res.Groups = append(res.Groups, newNotificationGroupResponseBody(group))
+	}
+
+	response.JSON(w, http.StatusOK, res)
+}
+
+// handleMarkNotificationsRead marks the given notifications and groups
+// read, or all of them when neither is sent.
+func (s *Server) handleMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		IDs       []string `json:"ids"`
+		GroupKeys []string `json:"group_keys"`
+	}
+
+	userId := currentUser(r).ID
+
+	var reqBodyParams reqBody
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&reqBodyParams)
+		if err != nil {
+			response.Err(w, r, badBody(err, "error proccessing request"))
+			return
+		}
+	}
+
+	readAt := sql.NullTime{Time: s.clock.Now(), Valid: true}
+	if len(reqBodyParams.IDs) == 0 && len(reqBodyParams.GroupKeys) == 0 {
+		_, err := s.db.MarkAllNotificationsRead(r.Context(), database.MarkAllNotificationsReadParams{
+			ReadAt: readAt,
+			UserID: userId,
+		})
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+	}
+	if len(reqBodyParams.IDs) > 0 {
+		_, err := s.db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+			ReadAt: readAt,
+			UserID: userId,
+			Ids:    reqBodyParams.IDs,
+		})
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+	}
+	if len(reqBodyParams.GroupKeys) > 0 {
+		_, err := s.db.MarkNotificationGroupsRead(r.Context(), database.MarkNotificationGroupsReadParams{
+			ReadAt:    readAt,
+			UserID:    userId,
+			GroupKeys: reqBodyParams.GroupKeys,
+		})
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+	}
+
+	unread, err := s.db.GetUnreadNotificationCount(r.Context(), userId)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]int64{"unread_count": unread})
+}
+
+// notificationPreferences returns every type with its setting; types the
+// user never changed are enabled.
+func (s *Server) notificationPreferences(ctx context.Context, userId string) (map[string]bool, error) {
+	prefs, err := s.db.GetNotificationPreferences(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]bool{}
+	for _, kind := range notificationTypes {
+		res[kind] = true
+	}
+	for _, pref := range prefs {
+		res[pref.Type] = pref.Enabled
+	}
+	return res, nil
+}
+
+func (s *Server) handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	prefs, err := s.notificationPreferences(r.Context(), userId)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, prefs)
+}
+
+// handleUpdateNotificationPreferences takes a map of type to enabled and
+// leaves types that aren't mentioned unchanged.
+func (s *Server) handleUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	var reqBodyParams map[string]bool
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error proccessing request"))
+		return
+	}
+
+	known := map[string]bool{}
+	for _, kind := range notificationTypes {
+		known[kind] = true
+	}
+	for kind := range reqBodyParams {
+		if !known[kind] {
+			response.Err(w, r, response.BadRequest("unknown notification type: "+kind))
+			return
+		}
+	}
+
+	for kind, enabled := range reqBodyParams {
+		err = s.db.UpsertNotificationPreference(r.Context(), database.UpsertNotificationPreferenceParams{
+			UserID:    userId,
+			Type:      kind,
+			Enabled:   enabled,
+			UpdatedAt: s.clock.Now(),
+		})
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+	}
+
+	prefs, err := s.notificationPreferences(r.Context(), userId)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, prefs)
+}
@@ -0,0 +1,473 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/netguard"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+)
+
+const (
+	deliveryStatusPending   = "pending"
+	deliveryStatusSucceeded = "succeeded"
+	deliveryStatusFailed    = "failed"
+)
+
+// deliveryLease is how long a claimed delivery is reserved for the worker
+// that claimed it. Workers claim one delivery at a time, so it only has to
+// outlast a single attempt.
+const deliveryLease = time.Minute
+
+type webhookEndpointResponseBody struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type webhookDeliveryAttemptResponseBody struct {
+	StatusCode  *int32    `json:"status_code"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int32     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+type webhookDeliveryResponseBody struct {
+	ID            string                               `json:"id"`
+	EventID       string                               `json:"event_id"`
+	Event         string                               `json:"event"`
+	Status        string                               `json:"status"`
+	Attempts      int32                                `json:"attempts"`
+	NextAttemptAt *time.Time                           `json:"next_attempt_at"`
+	CreatedAt     time.Time                            `json:"created_at"`
+	AttemptLog    []webhookDeliveryAttemptResponseBody `json:"attempt_log"`
+}
+
+// webhookEventBody is the JSON document POSTed to integrators.
+type webhookEventBody struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func newWebhookEndpointResponseBody(endpoint database.WebhookEndpoint) webhookEndpointResponseBody {
+	return webhookEndpointResponseBody{
+		ID:        endpoint.ID,
+		URL:       endpoint.Url,
+		Events:    endpoint.Events,
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
+
+func newWebhookDeliveryResponseBody(delivery database.WebhookDelivery, attempts []database.WebhookDeliveryAttempt) webhookDeliveryResponseBody {
+	res := webhookDeliveryResponseBody{
+		ID:         delivery.ID,
+		EventID:    delivery.EventID,
+		Event:      delivery.Event,
+		Status:     delivery.Status,
+		Attempts:   delivery.Attempts,
+		CreatedAt:  delivery.CreatedAt,
+		AttemptLog: []webhookDeliveryAttemptResponseBody{},
+	}
+	if delivery.Status == deliveryStatusPending {
+		res.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	for _, attempt := range attempts {
+		attemptRes := webhookDeliveryAttemptResponseBody{
+			Error:       attempt.Error.String,
+			DurationMs:  attempt.DurationMs,
+			AttemptedAt: attempt.AttemptedAt,
+		}
+		if attempt.StatusCode.Valid {
+			attemptRes.StatusCode = &attempt.StatusCode.Int32
+		}
+		res.AttemptLog = append(res.AttemptLog, attemptRes)
+	}
+	return res
+}
+
+// publishEvent queues a delivery of event to every endpoint subscribed to it.
+// Public events (chirps) go to all subscribers; when ownerId is set, only the
+// endpoints registered by that user receive it. Failures are logged rather
+// than failing the request that caused the event.
+func (s *Server) publishEvent(ctx context.Context, event, ownerId string, data any) {
+	if s.db == nil {
+		return
+	}
+
+	endpoints, err := s.db.GetWebhookEndpointsForEvent(ctx, database.GetWebhookEndpointsForEventParams{
+		Event:  event,
+		UserID: sql.NullString{String: ownerId, Valid: ownerId != ""},
+	})
+	if err != nil {
+		s.log(ctx).Error("publish failed", "event", event, "err", err)
+		return
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+
+	now := s.clock.Now()
+	eventBody := webhookEventBody{
+		ID:        s.ids.NewID(),
+		Type:      event,
+		CreatedAt: now,
+		Data:      data,
+	}
+	payload, err := json.Marshal(eventBody)
+	if err != nil {
+		s.log(ctx).Error("publish failed", "event", event, "err", err)
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		err = s.db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			ID:            s.ids.NewID(),
+			EndpointID:    endpoint.ID,
+			EventID:       eventBody.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        deliveryStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			s.log(ctx).Error("publish failed", "event", event, "endpoint_id", endpoint.ID, "err", err)
+		}
+	}
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_])@([a-zA-Z0-9_]{3,30})`)
+
+// chirpMentions returns the distinct handles mentioned in a chirp body.
+func chirpMentions(body string) []string {
+	seen := map[string]bool{}
+	handles := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(match[1])
+		if !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// publishChirpCreated publishes chirp.created, and a mention event and
+// notification for every existing user the chirp mentions.
+func (s *Server) publishChirpCreated(ctx context.Context, chirp chirpsResponseBody) {
+	if s.db == nil {
+		return
+	}
+
+	s.publishEvent(ctx, webhooks.EventChirpCreated, "", chirp)
+
+	for _, handle := range chirpMentions(chirp.Body) {
+		mentioned, err := s.store.GetUserByHandle(ctx, handle)
+		if err != nil || mentioned.DeleteAfter.Valid || mentioned.ID == chirp.UserId {
+			if err != nil && err != sql.ErrNoRows {
+				s.log(ctx).Error("looking up mentioned user", "handle", handle, "err", err)
+			}
+			continue
+		}
+		s.publishEvent(ctx, webhooks.EventMention, mentioned.ID, map[string]any{
+			"mentioned_user_id": mentioned.ID,
+			"chirp":             chirp,
+		})
+		s.notify(ctx, newNotification{
+			userId:  mentioned.ID,
+			kind:    notificationMention,
+			actorId: chirp.UserId,
+			chirpId: chirp.ID,
+		})
+	}
+}
+
+// runWebhookWorker sends due deliveries. Each is leased for deliveryLease
+// while it is attempted so several server instances can share the queue, and
+// a crashed worker's delivery is picked up again once the lease runs out.
+func (s *Server) runWebhookWorker(ctx context.Context, interval time.Duration) {
+	client := s.newOutboundClient()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			now := s.clock.Now()
+			deliveries, err := s.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+				LeaseUntil:    now.Add(deliveryLease),
+				Now:           now,
+				MaxDeliveries: 1,
+			})
+			if err != nil {
+				s.log(ctx).Error("webhook worker failed", "err", err)
+			}
+			if len(deliveries) == 0 {
+				break
+			}
+			s.attemptWebhookDelivery(ctx, client, deliveries[0])
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) attemptWebhookDelivery(ctx context.Context, client *http.Client, delivery database.WebhookDelivery) {
+	endpoint, err := s.db.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		s.log(ctx).Error("webhook delivery failed", "delivery_id", delivery.ID, "err", err)
+		return
+	}
+
+	started := s.clock.Now()
+	statusCode, deliverErr := webhooks.Deliver(ctx, client, endpoint.Url, endpoint.Secret, delivery.Event, delivery.ID, []byte(delivery.Payload))
+	finished := s.clock.Now()
+
+	attemptParams := database.CreateWebhookDeliveryAttemptParams{
+		ID:          s.ids.NewID(),
+		DeliveryID:  delivery.ID,
+		StatusCode:  sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+		DurationMs:  int32(finished.Sub(started).Milliseconds()),
+		AttemptedAt: started,
+	}
+	if deliverErr != nil {
+		// the log is shown to the endpoint's owner, so connection errors stay
+		// vague rather than describe what answered at the address
+		message := "could not reach endpoint"
+		if statusCode != 0 {
+			message = deliverErr.Error()
+		}
+		attemptParams.Error = sql.NullString{String: message, Valid: true}
+		s.log(ctx).Warn("webhook delivery attempt failed", "delivery_id", delivery.ID, "err", deliverErr)
+	}
+	err = s.db.CreateWebhookDeliveryAttempt(ctx, attemptParams)
+	if err != nil {
+		s.log(ctx).Error("webhook delivery failed", "delivery_id", delivery.ID, "err", err)
+	}
+
+	attempts := delivery.Attempts + 1
+	resultParams := database.UpdateWebhookDeliveryResultParams{
+		Status:          deliveryStatusSucceeded,
+		Attempts:        attempts,
+		NextAttemptAt:   finished,
+		LastAttemptedAt: sql.NullTime{Time: started, Valid: true},
+		ID:              delivery.ID,
+	}
+	if deliverErr != nil {
+		resultParams.Status = deliveryStatusPending
+		resultParams.NextAttemptAt = finished.Add(webhooks.Backoff(int(attempts)))
+		if attempts >= webhooks.MaxAttempts {
+			resultParams.Status = deliveryStatusFailed
+		}
+	}
+	s.metrics.webhookDeliveries.WithLabelValues(resultParams.Status).Inc()
+	err = s.db.UpdateWebhookDeliveryResult(ctx, resultParams)
+	if err != nil {
+		s.log(ctx).Error("webhook delivery failed", "delivery_id", delivery.ID, "err", err)
+	}
+}
+
+// mayBePublicHost rules out loopback and private hosts up front. Names are
+// checked again once resolved, when deliveries connect.
+func mayBePublicHost(host string) bool {
+	if strings.EqualFold(strings.TrimSuffix(host, "."), "localhost") {
+		return false
+	}
+	ip, err := netip.ParseAddr(host)
+	return err != nil || netguard.IsPublic(ip)
+}
+
+func (s *Server) handleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	userId := currentUser(r).ID
+
+	var reqBodyParams reqBody
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error proccessing request"))
+		return
+	}
+
+	endpointURL, err := url.Parse(reqBodyParams.URL)
+	if err != nil || endpointURL.Host == "" || (endpointURL.Scheme != "https" && (endpointURL.Scheme != "http" || s.env != "dev")) {
+		response.Err(w, r, response.BadRequest("webhook url must be an https URL"))
+		return
+	}
+	if s.env != "dev" && !mayBePublicHost(endpointURL.Hostname()) {
+		response.Err(w, r, response.BadRequest("webhook url must be a public address"))
+		return
+	}
+
+	if len(reqBodyParams.Events) == 0 {
+		response.Err(w, r, response.BadRequest("at least one event must be provided"))
+		return
+	}
+	for _, event := range reqBodyParams.Events {
+		if !webhooks.IsEvent(event) {
+			response.Err(w, r, response.BadRequest("unknown event: "+event))
+			return
+		}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	endpoint, err := s.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		ID:        s.ids.NewID(),
+		UserID:    userId,
+		Url:       endpointURL.String(),
+		Secret:    "whsec_" + secret,
+		Events:    reqBodyParams.Events,
+		CreatedAt: s.clock.Now(),
+		UpdatedAt: s.clock.Now(),
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	// the signing secret is only ever shown when the endpoint is created
+	res := newWebhookEndpointResponseBody(endpoint)
+	res.Secret = endpoint.Secret
+
+	response.JSON(w, http.StatusCreated, res)
+}
+
+func (s *Server) handleGetWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	endpoints, err := s.db.GetUserWebhookEndpoints(r.Context(), userId)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	results := []webhookEndpointResponseBody{}
+	for _, endpoint := range endpoints {
+		results = append(results, newWebhookEndpointResponseBody(endpoint))
+	}
+
+	response.JSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	deleted, err := s.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     r.PathValue("id"),
+		UserID: userId,
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+	if deleted == 0 {
+		response.Err(w, r, response.NotFound("webhook endpoint not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedWebhookEndpoint loads an endpoint and makes sure it belongs to userId.
+// It reports sql.ErrNoRows for endpoints owned by someone else so their
+// existence isn't leaked.
+func (s *Server) ownedWebhookEndpoint(ctx context.Context, endpointId, userId string) (database.WebhookEndpoint, error) {
+	endpoint, err := s.db.GetWebhookEndpoint(ctx, endpointId)
+	if err != nil {
+		return endpoint, err
+	}
+	if endpoint.UserID != userId {
+		return database.WebhookEndpoint{}, sql.ErrNoRows
+	}
+	return endpoint, nil
+}
+
+func (s *Server) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	endpoint, err := s.ownedWebhookEndpoint(r.Context(), r.PathValue("id"), userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("webhook endpoint not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	deliveries, err := s.db.GetEndpointWebhookDeliveries(r.Context(), database.GetEndpointWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(parseLimit(r, 20, 100)),
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	results := []webhookDeliveryResponseBody{}
+	for _, delivery := range deliveries {
+		attempts, err := s.db.GetWebhookDeliveryAttempts(r.Context(), delivery.ID)
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+
+		results = append(results, newWebhookDeliveryResponseBody(delivery, attempts))
+	}
+
+	response.JSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	delivery, err := s.db.GetWebhookDelivery(r.Context(), r.PathValue("id"))
+	if err == nil {
+		_, err = s.ownedWebhookEndpoint(r.Context(), delivery.EndpointID, userId)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("webhook delivery not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	err = s.db.ResetWebhookDelivery(r.Context(), database.ResetWebhookDeliveryParams{
+		NextAttemptAt: s.clock.Now(),
+		ID:            delivery.ID,
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
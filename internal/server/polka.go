@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/polka"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+const (
+	webhookStatusProcessing = "processing"
+	webhookStatusProcessed  = "processed"
+	webhookStatusIgnored    = "ignored"
+	webhookStatusFailed     = "failed"
+)
+
+type polkaWebhookBody struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserId      string     `json:"user_id"`
+		Plan        string     `json:"plan"`
+		PeriodStart *time.Time `json:"period_start"`
+		PeriodEnd   *time.Time `json:"period_end"`
+		CancelAt    *time.Time `json:"cancel_at"`
+	} `json:"data"`
+}
+
+// webhookClaimTimeout is how long a claimed event is left to the delivery
+// processing it before a retry may take it over.
+const webhookClaimTimeout = time.Minute
+
+// errPolkaUserNotFound is returned for events about users we don't know.
+// Retrying won't help, so it maps to 404 rather than 500.
+var errPolkaUserNotFound = fmt.Errorf("user not found")
+
+func (s *Server) handleUpdateUserChirpyRedWebhook(w http.ResponseWriter, r *http.Request) {
+	// without both, anyone could sign their own events
+	if s.polkaApiKey == "" || s.polkaSecret == "" {
+		s.metrics.polkaWebhooks.WithLabelValues("rejected").Inc()
+		response.Err(w, r, response.New(http.StatusServiceUnavailable, "not_configured", "polka webhooks are not configured"))
+		return
+	}
+
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || subtle.ConstantTimeCompare([]byte(apiKey), []byte(s.polkaApiKey)) != 1 {
+		s.metrics.polkaWebhooks.WithLabelValues("rejected").Inc()
+		response.Err(w, r, response.Unauthorized("invalid api key"))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error reading request body"))
+		return
+	}
+
+	err = polka.VerifySignature(s.polkaSecret, r.Header.Get(polka.SignatureHeader), body, s.clock.Now(), time.Minute*5)
+	if err != nil {
+		s.metrics.polkaWebhooks.WithLabelValues("rejected").Inc()
+		response.Err(w, r, response.Unauthorized("invalid webhook signature"))
+		return
+	}
+
+	var reqBodyParams polkaWebhookBody
+	err = json.Unmarshal(body, &reqBodyParams)
+	if err != nil {
+		s.metrics.polkaWebhooks.WithLabelValues("rejected").Inc()
+		response.Err(w, r, response.BadRequest("invalid webhook payload"))
+		return
+	}
+
+	if s.db == nil {
+		s.applyPolkaEventWithoutHistory(w, r, reqBodyParams)
+		return
+	}
+
+	// deliveries without an id are deduplicated by their exact payload
+	eventId := reqBodyParams.ID
+	if eventId == "" {
+		sum := sha256.Sum256(body)
+		eventId = "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	// recording the event claims it; a delivery that finds it already
+	// recorded may only claim it if it didn't complete and no one else is
+	// processing it, so concurrent retries can't both apply it
+	now := s.clock.Now()
+	event, err := s.db.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		ID:         eventId,
+		Provider:   "polka",
+		Event:      reqBodyParams.Event,
+		Payload:    string(body),
+		Status:     webhookStatusProcessing,
+		ReceivedAt: now,
+		ClaimedAt:  sql.NullTime{Time: now, Valid: true},
+	})
+	if err == sql.ErrNoRows {
+		event, err = s.db.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+			Now:         now,
+			ID:          eventId,
+			StaleBefore: now.Add(-webhookClaimTimeout),
+		})
+	}
+	if err == sql.ErrNoRows {
+		event, err = s.db.GetWebhookEvent(r.Context(), eventId)
+		if err == nil && (event.Status == webhookStatusProcessed || event.Status == webhookStatusIgnored) {
+			s.metrics.polkaWebhooks.WithLabelValues("duplicate").Inc()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err == nil {
+			// Polka retries, by which time the other delivery has finished
+			s.metrics.polkaWebhooks.WithLabelValues("in_progress").Inc()
+			response.Err(w, r, response.Conflict("event is already being processed"))
+			return
+		}
+	}
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	status, err := s.processPolkaEvent(r.Context(), reqBodyParams)
+	s.metrics.polkaWebhooks.WithLabelValues(status).Inc()
+
+	updateParams := database.UpdateWebhookEventStatusParams{
+		Status:      status,
+		ProcessedAt: sql.NullTime{Time: s.clock.Now(), Valid: true},
+		ID:          event.ID,
+	}
+	if err != nil {
+		updateParams.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+	updateErr := s.db.UpdateWebhookEventStatus(r.Context(), updateParams)
+	if updateErr != nil {
+		s.log(r.Context()).Error("updating webhook event status", "event_id", event.ID, "err", updateErr)
+	}
+
+	if err != nil {
+		if err == errPolkaUserNotFound {
+			response.Err(w, r, response.NotFound("user not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyPolkaEventWithoutHistory handles events for stores that keep no
+// event log or subscriptions: upgrades and renewals turn Chirpy Red on, and
+// downgrades that take effect now turn it off. Applying an event twice does
+// no harm.
+func (s *Server) applyPolkaEventWithoutHistory(w http.ResponseWriter, r *http.Request, body polkaWebhookBody) {
+	var isChirpyRed bool
+	switch body.Event {
+	case "user.upgraded", "user.renewed":
+		isChirpyRed = true
+	case "user.downgraded":
+		if body.Data.CancelAt != nil && body.Data.CancelAt.After(s.clock.Now()) {
+			s.metrics.polkaWebhooks.WithLabelValues(webhookStatusIgnored).Inc()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	default:
+		s.metrics.polkaWebhooks.WithLabelValues(webhookStatusIgnored).Inc()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	updated, err := s.store.UpdateUserSetChirpyRed(r.Context(), database.UpdateUserSetChirpyRedParams{
+		IsChirpyRed: isChirpyRed,
+		ID:          body.Data.UserId,
+	})
+	if err == nil && updated == 0 {
+		err = response.NotFound("user not found")
+	}
+	if err != nil {
+		s.metrics.polkaWebhooks.WithLabelValues(webhookStatusFailed).Inc()
+		response.Err(w, r, err)
+		return
+	}
+
+	s.metrics.polkaWebhooks.WithLabelValues(webhookStatusProcessed).Inc()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) processPolkaEvent(ctx context.Context, body polkaWebhookBody) (string, error) {
+	switch body.Event {
+	case "user.upgraded", "user.renewed", "user.downgraded", "user.payment_failed":
+	default:
+		return webhookStatusIgnored, nil
+	}
+
+	err := s.applySubscriptionEvent(ctx, body, s.clock.Now())
+	if err != nil {
+		return webhookStatusFailed, err
+	}
+	return webhookStatusProcessed, nil
+}
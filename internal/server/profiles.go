@@ -0,0 +1,233 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/gaba-bouliva/Chirpy/internal/store"
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+)
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// reservedHandles would shadow fixed routes under /api/users.
+var reservedHandles = map[string]bool{
+	"me":    true,
+	"admin": true,
+}
+
+type profileResponseBody struct {
+	ID             string    `json:"id"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	AvatarURL      string    `json:"avatar_url"`
+	Website        string    `json:"website"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	CreatedAt      time.Time `json:"created_at"`
+	ChirpCount     int64     `json:"chirp_count"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	// FollowedByMe is only set when the request is signed in.
+	FollowedByMe *bool `json:"followed_by_me,omitempty"`
+}
+
+func validateHandle(handle string) error {
+	if !handlePattern.MatchString(handle) {
+		return fmt.Errorf("handle must be 3-30 lowercase letters, digits or underscores")
+	}
+	if reservedHandles[handle] {
+		return fmt.Errorf("handle is reserved")
+	}
+	return nil
+}
+
+func validateProfileURL(field, rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http(s) URL", field)
+	}
+	return nil
+}
+
+func (s *Server) handleGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := s.store.GetUserByHandle(r.Context(), strings.ToLower(r.PathValue("handle")))
+	if err != nil || user.DeleteAfter.Valid {
+		if err == nil || err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("user not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	stats, err := s.db.GetUserProfileStats(r.Context(), user.ID)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	profile := profileResponseBody{
+		ID:             user.ID,
+		Handle:         user.Handle,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		AvatarURL:      user.AvatarUrl,
+		Website:        user.Website,
+		IsChirpyRed:    user.IsChirpyRed,
+		CreatedAt:      user.CreatedAt,
+		ChirpCount:     stats.ChirpCount,
+		FollowerCount:  stats.FollowerCount,
+		FollowingCount: stats.FollowingCount,
+	}
+
+	if viewer, ok := principalFrom(r.Context()); ok {
+		following, err := s.db.IsFollowing(r.Context(), database.IsFollowingParams{
+			FollowerID: viewer.User.ID,
+			FolloweeID: user.ID,
+		})
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+		profile.FollowedByMe = &following
+	}
+
+	response.JSON(w, http.StatusOK, profile)
+}
+
+func (s *Server) handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Handle      string `json:"handle"`
+		DisplayName string `json:"display_name"`
+		Bio         string `json:"bio"`
+		AvatarURL   string `json:"avatar_url"`
+		Website     string `json:"website"`
+	}
+
+	userId := currentUser(r).ID
+
+	var reqBodyParams reqBody
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error proccessing request"))
+		return
+	}
+
+	handle := strings.ToLower(strings.TrimSpace(reqBodyParams.Handle))
+	displayName := strings.TrimSpace(reqBodyParams.DisplayName)
+	bio := strings.TrimSpace(reqBodyParams.Bio)
+
+	err = validateHandle(handle)
+	if err == nil && utf8.RuneCountInString(displayName) > 50 {
+		err = fmt.Errorf("display name must be at most 50 characters")
+	}
+	if err == nil && utf8.RuneCountInString(bio) > 160 {
+		err = fmt.Errorf("bio must be at most 160 characters")
+	}
+	if err == nil {
+		err = validateProfileURL("avatar_url", reqBodyParams.AvatarURL)
+	}
+	if err == nil {
+		err = validateProfileURL("website", reqBodyParams.Website)
+	}
+	if err != nil {
+		response.Err(w, r, response.BadRequest(err.Error()))
+		return
+	}
+
+	updateProfileParams := database.UpdateUserProfileParams{
+		Handle:      handle,
+		DisplayName: displayName,
+		Bio:         bio,
+		AvatarUrl:   reqBodyParams.AvatarURL,
+		Website:     reqBodyParams.Website,
+		UpdatedAt:   s.clock.Now(),
+		ID:          userId,
+	}
+
+	updatedUser, err := s.store.UpdateUserProfile(r.Context(), updateProfileParams)
+	if err != nil {
+		if store.IsUniqueViolation(err) {
+			response.Err(w, r, response.Conflict("handle already taken"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, newUsersResponseBody(updatedUser))
+}
+
+func (s *Server) handleFollowUser(w http.ResponseWriter, r *http.Request) {
+	s.updateFollow(w, r, true)
+}
+
+func (s *Server) handleUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	s.updateFollow(w, r, false)
+}
+
+func (s *Server) updateFollow(w http.ResponseWriter, r *http.Request, follow bool) {
+	userId := currentUser(r).ID
+
+	followee, err := s.store.GetUserByHandle(r.Context(), strings.ToLower(r.PathValue("handle")))
+	if err != nil || followee.DeleteAfter.Valid {
+		if err == nil || err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("user not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	if followee.ID == userId {
+		response.Err(w, r, response.BadRequest("you can't follow yourself"))
+		return
+	}
+
+	var followed int64
+	if follow {
+		followed, err = s.db.CreateFollow(r.Context(), database.CreateFollowParams{
+			FollowerID: userId,
+			FolloweeID: followee.ID,
+			CreatedAt:  s.clock.Now(),
+		})
+	} else {
+		err = s.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+			FollowerID: userId,
+			FolloweeID: followee.ID,
+		})
+	}
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	// following someone already followed changes nothing, so tell no one
+	if followed > 0 {
+		s.publishEvent(r.Context(), webhooks.EventUserFollowed, followee.ID, map[string]string{
+			"follower_id": userId,
+			"followee_id": followee.ID,
+		})
+		s.notify(r.Context(), newNotification{
+			userId:  followee.ID,
+			kind:    notificationFollow,
+			actorId: userId,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+type userSummaryResponseBody struct {
+	ID          string `json:"id"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// parseLimit reads the "limit" query parameter, falling back to def and
+// capping it at max.
+func parseLimit(r *http.Request, def, max int) int {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Query().Get("q"), "@")))
+	if query == "" {
+		response.Err(w, r, response.BadRequest("search query not provided"))
+		return
+	}
+
+	users, err := s.db.SearchUsers(r.Context(), database.SearchUsersParams{
+		Prefix:     likeEscaper.Replace(query),
+		Handle:     query,
+		MaxResults: int32(parseLimit(r, 20, 50)),
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	results := []userSummaryResponseBody{}
+	for _, user := range users {
+		results = append(results, userSummaryResponseBody{
+			ID:          user.ID,
+			Handle:      user.Handle,
+			DisplayName: user.DisplayName,
+			Bio:         user.Bio,
+			AvatarURL:   user.AvatarUrl,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleGetUserSuggestions(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	suggestions, err := s.db.GetUserSuggestions(r.Context(), database.GetUserSuggestionsParams{
+		UserID: userId,
+		Limit:  int32(parseLimit(r, 10, 50)),
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	results := []userSummaryResponseBody{}
+	for _, user := range suggestions {
+		results = append(results, userSummaryResponseBody{
+			ID:          user.ID,
+			Handle:      user.Handle,
+			DisplayName: user.DisplayName,
+			Bio:         user.Bio,
+			AvatarURL:   user.AvatarUrl,
+		})
+	}
+
+	response.JSON(w, http.StatusOK, results)
+}
+
+// suggestionsBatch is how many users' suggestions the suggestions job
+// recomputes per run.
+const suggestionsBatch = 200
+
+// runSuggestionsJob periodically recomputes the "people you may know" cache
+// from friends of friends and hashtags users have in common. Each run only
+// refreshes the users whose suggestions are stalest, so the work per run
+// stays bounded as the number of users grows.
+func (s *Server) runSuggestionsJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.refreshUserSuggestions(ctx)
+		if err != nil {
+			s.log(ctx).Error("suggestions job failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) refreshUserSuggestions(ctx context.Context) error {
+	return s.db.InTx(ctx, func(q database.Querier) error {
+		userIds, err := q.GetStaleSuggestionUserIds(ctx, suggestionsBatch)
+		if err != nil || len(userIds) == 0 {
+			return err
+		}
+		err = q.DeleteUserSuggestions(ctx, userIds)
+		if err != nil {
+			return err
+		}
+		now := s.clock.Now()
+		err = q.RefreshUserSuggestions(ctx, database.RefreshUserSuggestionsParams{
+			UserIds:    userIds,
+			ComputedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+		return q.MarkUserSuggestionsComputed(ctx, database.MarkUserSuggestionsComputedParams{
+			ComputedAt: now,
+			UserIds:    userIds,
+		})
+	})
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/blob"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/mailer"
+	"github.com/gaba-bouliva/Chirpy/internal/pubsub"
+	"github.com/gaba-bouliva/Chirpy/internal/ratelimit"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/gaba-bouliva/Chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// Database is the full PostgreSQL data layer. Everything beyond users,
+// chirps and refresh tokens (follows, notifications, webhooks, federation,
+// ...) needs it, and is switched off when the store doesn't provide it.
+type Database interface {
+	store.Store
+	database.Querier
+	// InTx runs fn in a transaction that is committed if fn returns nil.
+	InTx(ctx context.Context, fn func(database.Querier) error) error
+}
+
+// Clock tells the server the time, so tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// IDGenerator makes the ids of new rows.
+type IDGenerator interface {
+	NewID() string
+}
+
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID() string {
+	return uuid.NewString()
+}
+
+// Config holds the server's settings.
+type Config struct {
+	// Env is the platform the server runs on; "dev" enables admin resets
+	// and plain HTTP webhook and federation targets.
+	Env           string
+	TokenSecret   string
+	PolkaAPIKey   string
+	PolkaSecret   string
+	DeletionGrace time.Duration
+	// AccessTokenTTL and RefreshTokenTTL are how long issued tokens stay
+	// valid, 5 minutes and 60 days when zero.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// BaseURL is the server's public URL, used in emailed links and
+	// ActivityPub ids.
+	BaseURL string
+	// MaxBodyBytes caps request bodies on routes that don't set their own
+	// limit; 64 KiB when zero.
+	MaxBodyBytes int64
+	// StaticDir is served under /app/.
+	StaticDir string
+	// MetricsToken is the bearer token required to scrape /metrics. Empty
+	// leaves /metrics open in dev and refuses it elsewhere.
+	MetricsToken string
+	// DatabaseURL is listened on for stream events published by other
+	// instances. Empty disables listening.
+	DatabaseURL string
+}
+
+// Deps are the server's dependencies. Store is required, and enables every
+// feature when it is also a Database; Blobs is needed for data exports. The
+// rest default to the system clock, UUIDs and logging emails.
+type Deps struct {
+	Store  store.Store
+	Blobs  blob.Store
+	Clock  Clock
+	IDs    IDGenerator
+	Mailer mailer.Mailer
+	Logger *slog.Logger
+}
+
+type Server struct {
+	fileserverHits *atomic.Int32
+	store          store.Store
+	db             Database // nil when the store only covers the core data
+	env            string
+	tokenSecret    string
+	polkaApiKey    string
+	polkaSecret    string
+	metricsToken   string
+	rateLimiter    *ratelimit.Limiter
+	deletionGrace  time.Duration
+	accessTTL      time.Duration
+	refreshTTL     time.Duration
+	maxBodyBytes   int64
+	baseURL        string
+	staticDir      string
+	databaseURL    string
+	blobs          blob.Store
+	mailer         mailer.Mailer
+	clock          Clock
+	ids            IDGenerator
+	broker         *pubsub.Broker
+	// localStream holds stream events when the store has nowhere to
+	// record them; streamMu orders publishing when nothing listens.
+	localStream *localStream
+	streamMu    sync.Mutex
+	// federationClient fetches remote actors and delivers activities.
+	federationClient *http.Client
+	logger           *slog.Logger
+	metrics          *metrics
+	// closing is cancelled by CloseStreams to end long-lived connections.
+	closing      context.Context
+	closeStreams context.CancelFunc
+	// workers tracks the background jobs and export builds, so shutdown can
+	// wait for them before the store is closed.
+	workers sync.WaitGroup
+}
+
+func New(cfg Config, deps Deps) *Server {
+	s := &Server{
+		fileserverHits: &atomic.Int32{},
+		store:          deps.Store,
+		env:            cfg.Env,
+		tokenSecret:    cfg.TokenSecret,
+		polkaApiKey:    cfg.PolkaAPIKey,
+		polkaSecret:    cfg.PolkaSecret,
+		metricsToken:   cfg.MetricsToken,
+		rateLimiter:    ratelimit.New(),
+		deletionGrace:  cfg.DeletionGrace,
+		accessTTL:      cfg.AccessTokenTTL,
+		refreshTTL:     cfg.RefreshTokenTTL,
+		maxBodyBytes:   cfg.MaxBodyBytes,
+		baseURL:        strings.TrimSuffix(cfg.BaseURL, "/"),
+		staticDir:      cfg.StaticDir,
+		databaseURL:    cfg.DatabaseURL,
+		blobs:          deps.Blobs,
+		mailer:         deps.Mailer,
+		clock:          deps.Clock,
+		ids:            deps.IDs,
+		broker:         pubsub.NewBroker(),
+		logger:         deps.Logger,
+	}
+	if db, ok := deps.Store.(Database); ok {
+		s.db = db
+	}
+	if s.accessTTL == 0 {
+		s.accessTTL = time.Minute * 5
+	}
+	if s.refreshTTL == 0 {
+		s.refreshTTL = time.Hour * 24 * 60
+	}
+	if s.maxBodyBytes == 0 {
+		s.maxBodyBytes = 64 << 10
+	}
+	s.closing, s.closeStreams = context.WithCancel(context.Background())
+	if s.staticDir == "" {
+		s.staticDir = "."
+	}
+	if s.mailer == nil {
+		s.mailer = mailer.LogMailer{}
+	}
+	if s.clock == nil {
+		s.clock = systemClock{}
+	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
+	if s.ids == nil {
+		s.ids = uuidGenerator{}
+	}
+	s.localStream = newLocalStream(s.clock.Now())
+	s.federationClient = s.newOutboundClient()
+	s.metrics = newMetrics(s)
+	return s
+}
+
+// Start runs the server's background jobs until ctx is done. Wait reports
+// when they have stopped.
+func (s *Server) Start(ctx context.Context) {
+	s.goWorker(func() { s.runUserDeletionJob(ctx, time.Hour) })
+	s.goWorker(func() { s.runRateLimitPruner(ctx, time.Minute) })
+	if s.db == nil {
+		return
+	}
+
+	s.goWorker(func() { s.runSuggestionsJob(ctx, time.Minute) })
+	s.goWorker(func() { s.runExportExpiryJob(ctx, time.Hour) })
+	s.goWorker(func() { s.runSubscriptionJob(ctx, time.Minute*15) })
+	s.goWorker(func() { s.runWebhookWorker(ctx, time.Second*5) })
+	s.goWorker(func() { s.runFederationWorker(ctx, time.Second*5) })
+	if s.databaseURL != "" {
+		s.goWorker(func() { s.runStreamListener(ctx, s.databaseURL) })
+	}
+	s.goWorker(func() { s.runStreamEventPruner(ctx, time.Hour, time.Hour*24) })
+}
+
+// goWorker runs fn in a goroutine that Wait waits for.
+func (s *Server) goWorker(fn func()) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until the background jobs started by Start, and any export
+// still being built, have finished. Jobs stop once Start's context is done;
+// Wait gives up when ctx is done first.
+func (s *Server) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// largeBodyBytes is the body limit for routes receiving documents from other
+// services, such as ActivityPub activities and Polka events.
+const largeBodyBytes = 1 << 20
+
+// Handler returns the server's routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("/app/", s.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(s.staticDir)))))
+
+	mux.HandleFunc("POST /api/chirps", s.requireAuth(s.handleChirp))
+	mux.HandleFunc("GET /api/chirps", s.handleGetAllChirps)
+	mux.HandleFunc("GET /api/chirps/{id}", s.handleGetChirpByID)
+	mux.HandleFunc("PUT /api/chirps/{id}", s.requireAuth(s.handleEditChirp))
+	mux.HandleFunc("DELETE /api/chirps/{id}", s.requireAuth(s.handleDeleteChirp))
+
+	mux.HandleFunc("POST /api/login", s.handleLogin)
+	mux.HandleFunc("POST /api/users", s.handleCreateUser)
+	// deprecated in favour of PATCH, which asks for the current password
+	mux.HandleFunc("PUT /api/users", s.requireAuth(s.handleUpdateUser))
+	mux.HandleFunc("PATCH /api/users", s.requireAuth(s.handlePatchUser))
+	mux.HandleFunc("GET /api/users/verify-email", s.handleVerifyEmail)
+	mux.HandleFunc("DELETE /api/users/me", s.requireAuth(s.handleDeleteUser))
+	mux.HandleFunc("PUT /api/users/me/profile", s.requireAuth(s.handleUpdateProfile))
+
+	mux.HandleFunc("GET /users/{id}/feed.rss", s.handleUserFeedRSS)
+	mux.HandleFunc("GET /users/{id}/feed.atom", s.handleUserFeedAtom)
+	mux.HandleFunc("GET /hashtags/{hashtag}/feed.rss", s.handleHashtagFeedRSS)
+	mux.HandleFunc("GET /hashtags/{hashtag}/feed.atom", s.handleHashtagFeedAtom)
+
+	mux.HandleFunc("POST /api/polka/webhooks", maxBody(largeBodyBytes, s.handleUpdateUserChirpyRedWebhook))
+
+	mux.HandleFunc("GET /api/stream", s.handleStream)
+
+	mux.HandleFunc("POST /api/refresh", s.handleRefreshToken)
+	mux.HandleFunc("POST /api/revoke", s.handleRevoke)
+
+	mux.HandleFunc("POST /admin/reset", s.resetMetrics)
+	mux.HandleFunc("GET /admin/metrics", s.countHits)
+	mux.Handle("GET /metrics", s.requireMetricsToken(s.metrics.handler()))
+
+	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+
+	// the rest needs the full database, and answers 501 without it
+	handle := mux.HandleFunc
+	if s.db == nil {
+		handle = func(pattern string, _ func(http.ResponseWriter, *http.Request)) {
+			mux.HandleFunc(pattern, handleNeedsDatabase)
+		}
+	}
+	handle("GET /api/ws", allowQueryToken(s.requireAuth(s.handleWebSocket)))
+
+	handle("POST /api/users/me/export", s.requireAuth(s.handleExportUser))
+	handle("GET /api/users/me/suggestions", s.requireAuth(s.handleGetUserSuggestions))
+	handle("GET /api/users/me/subscriptions", s.requireAuth(s.handleGetUserSubscriptions))
+	handle("GET /api/users/{handle}", s.optionalAuth(s.handleGetUserProfile))
+	handle("POST /api/users/{handle}/follow", s.requireAuth(s.handleFollowUser))
+	handle("DELETE /api/users/{handle}/follow", s.requireAuth(s.handleUnfollowUser))
+	handle("GET /api/exports/{id}", s.handleDownloadExport)
+
+	handle("GET /api/search/users", s.handleSearchUsers)
+
+	handle("GET /.well-known/webfinger", s.handleWebFinger)
+	handle("GET /ap/users/{id}", s.handleGetActor)
+	handle("GET /ap/users/{id}/outbox", s.handleGetOutbox)
+	handle("GET /ap/users/{id}/followers", s.handleGetFollowers)
+	handle("POST /ap/users/{id}/inbox", maxBody(largeBodyBytes, s.handleInbox))
+	handle("POST /ap/inbox", maxBody(largeBodyBytes, s.handleInbox))
+	handle("GET /ap/chirps/{id}", s.handleGetNote)
+	handle("POST /api/users/me/remote-follows", s.requireAuth(s.handleRemoteFollow))
+	handle("DELETE /api/users/me/remote-follows", s.requireAuth(s.handleRemoteUnfollow))
+	handle("GET /api/users/me/remote-timeline", s.requireAuth(s.handleGetRemoteTimeline))
+
+	handle("GET /api/notifications", s.requireAuth(s.handleGetNotifications))
+	handle("POST /api/notifications/read", s.requireAuth(s.handleMarkNotificationsRead))
+	handle("GET /api/notifications/preferences", s.requireAuth(s.handleGetNotificationPreferences))
+	handle("PUT /api/notifications/preferences", s.requireAuth(s.handleUpdateNotificationPreferences))
+
+	handle("POST /api/webhooks", s.requireAuth(s.handleCreateWebhookEndpoint))
+	handle("GET /api/webhooks", s.requireAuth(s.handleGetWebhookEndpoints))
+	handle("DELETE /api/webhooks/{id}", s.requireAuth(s.handleDeleteWebhookEndpoint))
+	handle("GET /api/webhooks/{id}/deliveries", s.requireAuth(s.handleGetWebhookDeliveries))
+	handle("POST /api/webhooks/deliveries/{id}/redeliver", s.requireAuth(s.handleRedeliverWebhook))
+
+	return response.WithRequestID(s.accessLog(s.limitBodies(mux)))
+}
+
+func handleNeedsDatabase(w http.ResponseWriter, r *http.Request) {
+	response.Err(w, r, response.New(http.StatusNotImplemented, "not_implemented", "this feature needs the PostgreSQL database"))
+}
+
+// CloseStreams ends every event stream and WebSocket connection. Register
+// it with http.Server.RegisterOnShutdown: Shutdown would otherwise wait for
+// streams until its deadline and leave WebSockets open.
+func (s *Server) CloseStreams() {
+	s.closeStreams()
+}
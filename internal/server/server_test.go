@@ -0,0 +1,1507 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/activitypub"
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/polka"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/gaba-bouliva/Chirpy/internal/store"
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+	"github.com/gaba-bouliva/Chirpy/sql/schema"
+	"github.com/gorilla/websocket"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type sequentialIDs struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (g *sequentialIDs) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", g.n)
+}
+
+func newTestServer(t *testing.T) (http.Handler, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Now()}
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  store.NewMemory(),
+		Clock:  clock,
+		IDs:    &sequentialIDs{},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	return srv.Handler(), clock
+}
+
+func doJSON(t *testing.T, h http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// signupAndLogin creates a user with the password "correct horse", whose
+// handle is the email's local part, and logs them in.
+func signupAndLogin(t *testing.T, h http.Handler, email string) usersResponseBody {
+	t.Helper()
+	handle, _, _ := strings.Cut(email, "@")
+	doJSON(t, h, http.MethodPost, "/api/users", "", map[string]string{
+		"email":    email,
+		"password": "correct horse",
+		"handle":   handle,
+	})
+	rec := doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    email,
+		"password": "correct horse",
+	})
+	var loggedIn usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &loggedIn); err != nil || loggedIn.Token == "" {
+		t.Fatalf("expected to log in, got %d: %s", rec.Code, rec.Body.String())
+	}
+	return loggedIn
+}
+
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected JSON error body, got %q", rec.Body.String())
+	}
+	return body.Error.Code
+}
+
+func TestCreateUserAndLogin(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	rec := doJSON(t, h, http.MethodPost, "/api/users", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+		"handle":   "ada",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != "00000000-0000-0000-0000-000000000001" || created.Handle != "ada" {
+		t.Fatalf("unexpected user %+v", created)
+	}
+
+	rec = doJSON(t, h, http.MethodPost, "/api/users", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	if rec.Code != http.StatusConflict || errorCode(t, rec) != "conflict" {
+		t.Fatalf("expected conflict for a taken email, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "wrong",
+	})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong password, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var loggedIn usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &loggedIn); err != nil {
+		t.Fatal(err)
+	}
+	if loggedIn.Token == "" || loggedIn.RefreshToken == "" {
+		t.Fatalf("expected tokens, got %+v", loggedIn)
+	}
+}
+
+func TestRefreshTokenExpires(t *testing.T) {
+	h, clock := newTestServer(t)
+
+	doJSON(t, h, http.MethodPost, "/api/users", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	rec := doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	var loggedIn usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &loggedIn); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = doJSON(t, h, http.MethodPost, "/api/refresh", loggedIn.RefreshToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	clock.Advance(time.Hour * 24 * 61)
+	rec = doJSON(t, h, http.MethodPost, "/api/refresh", loggedIn.RefreshToken, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected an expired refresh token to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"missing token", ""},
+		{"invalid token", "not-a-jwt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doJSON(t, h, http.MethodPost, "/api/chirps", tt.token, map[string]string{"body": "hello"})
+			if rec.Code != http.StatusUnauthorized || errorCode(t, rec) != "unauthorized" {
+				t.Fatalf("expected 401, got %d: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUserDeletion(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret", DeletionGrace: time.Hour}, Deps{
+		Store:  store.NewMemory(),
+		Clock:  clock,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	h := srv.Handler()
+	user := signupAndLogin(t, h, "ada@example.com")
+
+	rec := doJSON(t, h, http.MethodDelete, "/api/users/me", user.Token, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected deletion to be scheduled, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// the access token is still unexpired but no longer accepted
+	rec = doJSON(t, h, http.MethodPost, "/api/chirps", user.Token, map[string]string{"body": "hello"})
+	if rec.Code != http.StatusUnauthorized || errorCode(t, rec) != "pending_deletion" {
+		t.Fatalf("expected 401 pending_deletion, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(t, h, http.MethodDelete, "/api/users/me", user.Token, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected deletion not to be rescheduled, got %d", rec.Code)
+	}
+	rec = doJSON(t, h, http.MethodPost, "/api/refresh", user.RefreshToken, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the refresh token to be revoked, got %d", rec.Code)
+	}
+
+	// logging in again cancels the deletion
+	user = signupAndLogin(t, h, "ada@example.com")
+	rec = doJSON(t, h, http.MethodPost, "/api/chirps", user.Token, map[string]string{"body": "hello"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected to chirp after cancelling, got %d: %s", rec.Code, rec.Body.String())
+	}
+	clock.Advance(time.Hour * 2)
+	if n, err := srv.deleteUsersDueForDeletion(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected no users to be purged, got %d (%v)", n, err)
+	}
+
+	// once the grace period has passed the account is purged
+	rec = doJSON(t, h, http.MethodDelete, "/api/users/me", user.Token, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected deletion to be scheduled, got %d", rec.Code)
+	}
+	clock.Advance(time.Minute * 30)
+	if n, err := srv.deleteUsersDueForDeletion(context.Background()); err != nil || n != 0 {
+		t.Fatalf("expected no users to be purged within the grace period, got %d (%v)", n, err)
+	}
+	clock.Advance(time.Hour)
+	if n, err := srv.deleteUsersDueForDeletion(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected the user to be purged, got %d (%v)", n, err)
+	}
+	rec = doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a purged user not to log in, got %d", rec.Code)
+	}
+}
+
+func TestGetChirpNotFound(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	rec := doJSON(t, h, http.MethodGet, "/api/chirps/missing", "", nil)
+	if rec.Code != http.StatusNotFound || errorCode(t, rec) != "not_found" {
+		t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestChirpLifecycle(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	doJSON(t, h, http.MethodPost, "/api/users", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+		"handle":   "ada",
+	})
+	rec := doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	var loggedIn usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &loggedIn); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = doJSON(t, h, http.MethodPost, "/api/chirps", loggedIn.Token, map[string]string{"body": "what a kerfuffle"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created chirpsResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Body != "what a ****" || created.Author.Handle != "ada" {
+		t.Fatalf("unexpected chirp %+v", created)
+	}
+
+	rec = doJSON(t, h, http.MethodGet, "/api/chirps?author_id="+loggedIn.ID, "", nil)
+	var chirps []chirpsResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &chirps); err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 1 || chirps[0].ID != created.ID {
+		t.Fatalf("expected the new chirp to be listed, got %+v", chirps)
+	}
+
+	rec = doJSON(t, h, http.MethodDelete, "/api/chirps/"+created.ID, loggedIn.Token, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(t, h, http.MethodGet, "/api/chirps/"+created.ID, "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected the deleted chirp to be gone, got %d", rec.Code)
+	}
+}
+
+func TestDatabaseRoutesNeedDatabase(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	rec := doJSON(t, h, http.MethodGet, "/api/search/users?q=ada", "", nil)
+	if rec.Code != http.StatusNotImplemented || errorCode(t, rec) != "not_implemented" {
+		t.Fatalf("expected search to be unavailable on the memory store, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPolkaWebhookWithoutDatabase(t *testing.T) {
+	h, _ := newTestServer(t)
+	rec := doJSON(t, h, http.MethodPost, "/api/polka/webhooks", "", map[string]string{"event": "user.upgraded"})
+	if rec.Code != http.StatusServiceUnavailable || errorCode(t, rec) != "not_configured" {
+		t.Fatalf("expected 503 without polka credentials, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret", PolkaAPIKey: "key", PolkaSecret: "secret"}, Deps{
+		Store:  store.NewMemory(),
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	h = srv.Handler()
+	user := signupAndLogin(t, h, "ada@example.com")
+
+	send := func(event string) int {
+		body := fmt.Sprintf(`{"id":"evt-%s","event":%q,"data":{"user_id":%q}}`, event, event, user.ID)
+		req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(body))
+		req.Header.Set("Authorization", "ApiKey key")
+		req.Header.Set(polka.SignatureHeader, webhooks.Sign("secret", time.Now(), []byte(body)))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	isChirpyRed := func() bool {
+		return signupAndLogin(t, h, "ada@example.com").IsChirpyRed
+	}
+
+	if code := send("user.upgraded"); code != http.StatusNoContent || !isChirpyRed() {
+		t.Fatalf("expected the upgrade to apply, got %d", code)
+	}
+	if code := send("user.downgraded"); code != http.StatusNoContent || isChirpyRed() {
+		t.Fatalf("expected the downgrade to apply, got %d", code)
+	}
+	if code := send("user.exploded"); code != http.StatusNoContent {
+		t.Fatalf("expected unknown events to be ignored, got %d", code)
+	}
+}
+
+func TestHashtagFeedPages(t *testing.T) {
+	db := store.NewMemory()
+	h := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  db,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}).Handler()
+	user := signupAndLogin(t, h, "ada@example.com")
+
+	start := time.Now().UTC()
+	for i := range feedPageSize + 1 {
+		created := start.Add(time.Duration(i) * time.Second)
+		_, err := db.CreateChirp(context.Background(), database.CreateChirpParams{
+			ID:        fmt.Sprintf("chirp-%02d", i),
+			CreatedAt: created,
+			UpdatedAt: created,
+			Body:      fmt.Sprintf("chirp %d #go", i),
+			UserID:    user.ID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := doJSON(t, h, http.MethodGet, "/hashtags/go/feed.atom", "", nil)
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "<entry>") != feedPageSize {
+		t.Fatalf("expected a full first page, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "chirp-20") || strings.Contains(rec.Body.String(), "chirp-00") {
+		t.Fatal("expected the first page to hold the newest chirps")
+	}
+
+	rec = doJSON(t, h, http.MethodGet, "/hashtags/go/feed.atom?page=2", "", nil)
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "<entry>") != 1 || !strings.Contains(rec.Body.String(), "chirp-00") {
+		t.Fatalf("expected the oldest chirp on the last page, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(t, h, http.MethodGet, "/hashtags/go/feed.atom?page=3", "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 past the last page, got %d", rec.Code)
+	}
+}
+
+func TestWaitForBackgroundJobs(t *testing.T) {
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  store.NewMemory(),
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.Start(ctx)
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancelWait()
+	if err := srv.Wait(waitCtx); err != context.DeadlineExceeded {
+		t.Fatalf("expected Wait to give up while the jobs run, got %v", err)
+	}
+
+	cancel()
+	waitCtx, cancelWait = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelWait()
+	if err := srv.Wait(waitCtx); err != nil {
+		t.Fatalf("expected the jobs to stop, got %v", err)
+	}
+}
+
+func TestWebSocketSessionEnded(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  store.NewMemory(),
+		Clock:  clock,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	h := srv.Handler()
+	user := signupAndLogin(t, h, "ada@example.com")
+	since := clock.Now()
+
+	check := func(token, want string) {
+		t.Helper()
+		reason, err := srv.wsSessionEnded(context.Background(), token, since)
+		if err != nil || reason != want {
+			t.Fatalf("expected %q, got %q (%v)", want, reason, err)
+		}
+	}
+
+	check(user.Token, "")
+	check("not-a-token", "session expired")
+
+	rec := doJSON(t, h, http.MethodPost, "/api/revoke", user.RefreshToken, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the refresh token to be revoked, got %d", rec.Code)
+	}
+	check(user.Token, "session revoked")
+
+	// a new login keeps connections opened before it alive
+	user = signupAndLogin(t, h, "ada@example.com")
+	check(user.Token, "")
+
+	rec = doJSON(t, h, http.MethodDelete, "/api/users/me", user.Token, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected deletion to be scheduled, got %d", rec.Code)
+	}
+	check(user.Token, "account scheduled for deletion")
+}
+
+func TestWebSocketReauthenticate(t *testing.T) {
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  &streamDB{Memory: store.NewMemory()},
+		IDs:    &sequentialIDs{},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	defer srv.CloseStreams()
+
+	ada := signupAndLogin(t, ts.Config.Handler, "ada@example.com")
+	bob := signupAndLogin(t, ts.Config.Handler, "bob@example.com")
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/ws?access_token="+ada.Token, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	send := func(token, want string) {
+		t.Helper()
+		if err := conn.WriteJSON(wsClientMessage{Type: "auth", Token: token}); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		var msg wsServerMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != want {
+			t.Fatalf("expected %q, got %+v", want, msg)
+		}
+	}
+
+	send("not-a-token", "error")
+	// another user's token can't take the connection over
+	send(bob.Token, "error")
+
+	ada = signupAndLogin(t, ts.Config.Handler, "ada@example.com")
+	send(ada.Token, "authenticated")
+}
+
+// notificationsDB is a Database that keeps notifications and preferences
+// in memory. Queries it doesn't implement panic.
+type notificationsDB struct {
+	*store.Memory
+	unimplementedQuerier
+
+	mu            sync.Mutex
+	prefs         map[string]bool
+	notifications []database.CreateNotificationParams
+}
+
+type unimplementedQuerier struct {
+	database.Querier
+}
+
+func newNotificationsDB() *notificationsDB {
+	return &notificationsDB{Memory: store.NewMemory(), prefs: map[string]bool{}}
+}
+
+func (d *notificationsDB) InTx(ctx context.Context, fn func(database.Querier) error) error {
+	return fn(d)
+}
+
+func (d *notificationsDB) IsNotificationEnabled(ctx context.Context, arg database.IsNotificationEnabledParams) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	enabled, ok := d.prefs[arg.UserID+"/"+arg.Type]
+	return enabled || !ok, nil
+}
+
+func (d *notificationsDB) GetNotificationPreferences(ctx context.Context, userID string) ([]database.NotificationPreference, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var prefs []database.NotificationPreference
+	for key, enabled := range d.prefs {
+		user, kind, _ := strings.Cut(key, "/")
+		if user == userID {
+			prefs = append(prefs, database.NotificationPreference{UserID: user, Type: kind, Enabled: enabled})
+		}
+	}
+	return prefs, nil
+}
+
+func (d *notificationsDB) UpsertNotificationPreference(ctx context.Context, arg database.UpsertNotificationPreferenceParams) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prefs[arg.UserID+"/"+arg.Type] = arg.Enabled
+	return nil
+}
+
+func (d *notificationsDB) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notifications = append(d.notifications, arg)
+	return database.Notification{ID: arg.ID, UserID: arg.UserID, Type: arg.Type, ActorID: arg.ActorID, ChirpID: arg.ChirpID, GroupKey: arg.GroupKey, CreatedAt: arg.CreatedAt}, nil
+}
+
+func (d *notificationsDB) GetRemoteActor(ctx context.Context, id string) (database.RemoteActor, error) {
+	return database.RemoteActor{ID: id, PreferredUsername: "carol", Name: "Carol"}, nil
+}
+
+func (d *notificationsDB) LockStreamEvents(ctx context.Context) error {
+	return nil
+}
+
+func (d *notificationsDB) CreateStreamEvent(ctx context.Context, arg database.CreateStreamEventParams) (database.StreamEvent, error) {
+	return database.StreamEvent{}, errors.New("stream events are not stored")
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	db := newNotificationsDB()
+	clock := &fakeClock{now: time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)}
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  db,
+		Clock:  clock,
+		IDs:    &sequentialIDs{},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	h := srv.Handler()
+	ada := signupAndLogin(t, h, "ada@example.com")
+	bob := signupAndLogin(t, h, "bob@example.com")
+
+	prefs := func(rec *httptest.ResponseRecorder) map[string]bool {
+		t.Helper()
+		var res map[string]bool
+		if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &res) != nil {
+			t.Fatalf("expected preferences, got %d: %s", rec.Code, rec.Body.String())
+		}
+		return res
+	}
+
+	got := prefs(doJSON(t, h, http.MethodGet, "/api/notifications/preferences", ada.Token, nil))
+	if !got[notificationFollow] || !got[notificationMention] {
+		t.Fatalf("expected every type on by default, got %v", got)
+	}
+	got = prefs(doJSON(t, h, http.MethodPut, "/api/notifications/preferences", ada.Token, map[string]bool{notificationMention: false}))
+	if !got[notificationFollow] || got[notificationMention] {
+		t.Fatalf("expected only mentions off, got %v", got)
+	}
+	rec := doJSON(t, h, http.MethodPut, "/api/notifications/preferences", ada.Token, map[string]bool{"likes": true})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected unknown types to be rejected, got %d", rec.Code)
+	}
+
+	ctx := context.Background()
+	srv.notify(ctx, newNotification{userId: ada.ID, kind: notificationMention, actorId: bob.ID, chirpId: "c1"})
+	srv.notify(ctx, newNotification{userId: ada.ID, kind: notificationFollow, actorId: ada.ID})
+	srv.notify(ctx, newNotification{userId: ada.ID, kind: notificationFollow, actorId: bob.ID})
+	srv.notify(ctx, newNotification{userId: bob.ID, kind: notificationMention, actorId: ada.ID, chirpId: "c2"})
+	srv.notify(ctx, newNotification{userId: ada.ID, kind: notificationLike, remoteActorId: "https://remote.test/users/carol", chirpId: "c1"})
+
+	if len(db.notifications) != 3 {
+		t.Fatalf("expected the muted mention and the self-follow to be skipped, got %+v", db.notifications)
+	}
+	if n := db.notifications[0]; n.UserID != ada.ID || n.ActorID.String != bob.ID || n.GroupKey != "follow:2026-10-19" {
+		t.Fatalf("unexpected follow notification %+v", n)
+	}
+	if n := db.notifications[1]; n.UserID != bob.ID || n.GroupKey != "mention:c2" {
+		t.Fatalf("unexpected mention notification %+v", n)
+	}
+	if n := db.notifications[2]; n.ActorID.Valid || n.RemoteActorID.String != "https://remote.test/users/carol" || n.GroupKey != "like:c1" {
+		t.Fatalf("unexpected like notification %+v", n)
+	}
+}
+
+// streamDB is a Database holding stream events in memory.
+type streamDB struct {
+	*store.Memory
+	unimplementedQuerier
+
+	events []database.StreamEvent
+}
+
+func (d *streamDB) InTx(ctx context.Context, fn func(database.Querier) error) error {
+	return fn(d)
+}
+
+func (d *streamDB) LockStreamEvents(ctx context.Context) error {
+	return nil
+}
+
+func (d *streamDB) GetStreamEventsAfter(ctx context.Context, arg database.GetStreamEventsAfterParams) ([]database.StreamEvent, error) {
+	var rows []database.StreamEvent
+	for _, e := range d.events {
+		if e.ID > arg.AfterID && len(rows) < int(arg.MaxEvents) {
+			rows = append(rows, e)
+		}
+	}
+	return rows, nil
+}
+
+func (d *streamDB) GetVisibleChirpIds(ctx context.Context, ids []string) ([]string, error) {
+	var visible []string
+	for _, id := range ids {
+		if _, err := d.GetChirpById(ctx, id); err == nil {
+			visible = append(visible, id)
+		}
+	}
+	return visible, nil
+}
+
+func (d *streamDB) CreateStreamEvent(ctx context.Context, arg database.CreateStreamEventParams) (database.StreamEvent, error) {
+	e := database.StreamEvent{
+		ID:          int64(len(d.events) + 1),
+		Event:       arg.Event,
+		AuthorID:    arg.AuthorID,
+		Hashtags:    arg.Hashtags,
+		Payload:     arg.Payload,
+		CreatedAt:   arg.CreatedAt,
+		RecipientID: arg.RecipientID,
+	}
+	d.events = append(d.events, e)
+	return e, nil
+}
+
+func (d *streamDB) NotifyStreamEvent(ctx context.Context, id string) error {
+	return nil
+}
+
+// likesDB is a streamDB that also keeps remote likes and the
+// notifications they cause.
+type likesDB struct {
+	streamDB
+
+	likes         map[[2]string]string // chirp and actor ids to the like's activity id
+	notifications []database.CreateNotificationParams
+}
+
+func (d *likesDB) IsNotificationEnabled(ctx context.Context, arg database.IsNotificationEnabledParams) (bool, error) {
+	return true, nil
+}
+
+func (d *likesDB) GetRemoteActor(ctx context.Context, id string) (database.RemoteActor, error) {
+	return database.RemoteActor{ID: id, PreferredUsername: "bob"}, nil
+}
+
+func (d *likesDB) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	d.notifications = append(d.notifications, arg)
+	return database.Notification{ID: arg.ID, UserID: arg.UserID, Type: arg.Type, RemoteActorID: arg.RemoteActorID, ChirpID: arg.ChirpID, GroupKey: arg.GroupKey, CreatedAt: arg.CreatedAt}, nil
+}
+
+func (d *likesDB) CreateRemoteLike(ctx context.Context, arg database.CreateRemoteLikeParams) (bool, error) {
+	key := [2]string{arg.ChirpID, arg.ActorID}
+	_, exists := d.likes[key]
+	d.likes[key] = arg.ActivityID
+	return !exists, nil
+}
+
+func (d *likesDB) DeleteRemoteLike(ctx context.Context, arg database.DeleteRemoteLikeParams) ([]string, error) {
+	var chirpIds []string
+	for key, activityId := range d.likes {
+		if activityId == arg.ActivityID && key[1] == arg.ActorID {
+			delete(d.likes, key)
+			chirpIds = append(chirpIds, key[0])
+		}
+	}
+	return chirpIds, nil
+}
+
+// remoteNotesDB is a Database that keeps remote notes from followed actors.
+type remoteNotesDB struct {
+	*store.Memory
+	unimplementedQuerier
+
+	notes map[string]database.CreateRemoteNoteParams
+}
+
+func (d *remoteNotesDB) InTx(ctx context.Context, fn func(database.Querier) error) error {
+	return fn(d)
+}
+
+func (d *remoteNotesDB) IsRemoteActorFollowed(ctx context.Context, actorID string) (bool, error) {
+	return true, nil
+}
+
+func (d *remoteNotesDB) CreateRemoteNote(ctx context.Context, arg database.CreateRemoteNoteParams) error {
+	d.notes[arg.ID] = arg
+	return nil
+}
+
+func TestRemoteNoteIngest(t *testing.T) {
+	ctx := context.Background()
+	db := &remoteNotesDB{Memory: store.NewMemory(), notes: map[string]database.CreateRemoteNoteParams{}}
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret", BaseURL: "http://chirpy.test"}, Deps{
+		Store:  db,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	actor := database.RemoteActor{ID: "http://remote.test/users/bob", PreferredUsername: "bob"}
+	create := func(noteId, content string) activitypub.Activity {
+		return activitypub.Activity{
+			ID:    noteId + "/activity",
+			Type:  "Create",
+			Actor: actor.ID,
+			Object: activitypub.NewObject(activitypub.Note{
+				ID:           noteId,
+				Type:         "Note",
+				AttributedTo: actor.ID,
+				Content:      content,
+			}),
+		}
+	}
+
+	err := srv.processActivity(ctx, actor, create("http://remote.test/notes/1", `<p onclick="x()">hi <script>alert(1)</script><a href="javascript:x()">there</a></p>`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<p>hi <a rel="nofollow noopener noreferrer">there</a></p>`
+	if got := db.notes["http://remote.test/notes/1"].Content; got != want {
+		t.Fatalf("stored content %q, want %q", got, want)
+	}
+
+	// bob's server can't create or overwrite notes hosted elsewhere
+	err = srv.processActivity(ctx, actor, create("http://other.test/notes/1", "hijacked"), nil)
+	if !errors.Is(err, errInvalidActivity) {
+		t.Fatalf("got %v, want errInvalidActivity", err)
+	}
+	if _, ok := db.notes["http://other.test/notes/1"]; ok {
+		t.Fatal("note hosted elsewhere was stored")
+	}
+}
+
+func TestRemoteLikeReactions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	db := &likesDB{streamDB: streamDB{Memory: store.NewMemory()}, likes: map[[2]string]string{}}
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: "u1", Email: "ada@example.com", Handle: "ada", CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.CreateChirp(ctx, database.CreateChirpParams{ID: "c1", Body: "hello", UserID: user.ID, CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret", BaseURL: "http://chirpy.test"}, Deps{
+		Store:  db,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	sub := srv.broker.Subscribe(8)
+	defer srv.broker.Unsubscribe(sub)
+
+	actor := database.RemoteActor{ID: "http://remote.test/users/bob", PreferredUsername: "bob"}
+	like := activitypub.Activity{
+		ID:     "http://remote.test/likes/1",
+		Type:   "Like",
+		Actor:  actor.ID,
+		Object: activitypub.NewObject(srv.noteURL("c1")),
+	}
+	undo := activitypub.Activity{
+		ID:     "http://remote.test/likes/1/undo",
+		Type:   "Undo",
+		Actor:  actor.ID,
+		Object: activitypub.NewObject(like),
+	}
+
+	var events []string
+	for _, activity := range []activitypub.Activity{like, like, undo} {
+		if err := srv.processActivity(ctx, actor, activity, nil); err != nil {
+			t.Fatal(err)
+		}
+	drain:
+		for {
+			select {
+			case e := <-sub.C:
+				if e.Type != notificationCreatedEvent && (e.AuthorID != user.ID || !strings.Contains(string(e.Data), `"chirp_id":"c1"`)) {
+					t.Fatalf("unexpected event %+v", e)
+				}
+				events = append(events, e.Type)
+			default:
+				break drain
+			}
+		}
+	}
+	// the redelivered like isn't pushed or notified again
+	if fmt.Sprint(events) != "[chirp.liked notification.created chirp.unliked]" {
+		t.Fatalf("unexpected events %v", events)
+	}
+	if len(db.notifications) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(db.notifications))
+	}
+	n := db.notifications[0]
+	if n.UserID != user.ID || n.Type != notificationLike || n.RemoteActorID.String != actor.ID || n.ActorID.Valid || n.ChirpID.String != "c1" {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	db := &streamDB{Memory: store.NewMemory()}
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: "u1", Email: "ada@example.com", Handle: "ada", CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		_, err := db.CreateChirp(ctx, database.CreateChirpParams{ID: id, Body: "chirp " + id, UserID: user.ID, CreatedAt: now, UpdatedAt: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	event := func(id int64, kind, chirpId string) database.StreamEvent {
+		return database.StreamEvent{
+			ID:        id,
+			Event:     kind,
+			AuthorID:  user.ID,
+			Hashtags:  []string{},
+			Payload:   fmt.Sprintf(`{"id":%q}`, chirpId),
+			CreatedAt: now,
+		}
+	}
+	db.events = []database.StreamEvent{
+		event(1, "chirp.created", "a"),
+		event(2, "chirp.created", "b"),
+		event(3, "chirp.created", "c"),
+		event(4, "chirp.created", "d"),
+		event(5, "chirp.deleted", "d"),
+	}
+
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  db,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	// a cancelled request ends the stream once the replay has been sent
+	reqCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/stream", nil).WithContext(reqCtx)
+	req.Header.Set("Last-Event-ID", "2")
+	rec := httptest.NewRecorder()
+	srv.handleStream(rec, req)
+
+	if ids := streamEventIds(rec.Body.String()); fmt.Sprint(ids) != "[3 5]" {
+		t.Fatalf("expected the events after 2, without the deleted chirp, got %v in %q", ids, rec.Body.String())
+	}
+}
+
+func streamEventIds(body string) []string {
+	var ids []string
+	for _, line := range strings.Split(body, "\n") {
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestStreamWithoutDatabase(t *testing.T) {
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  store.NewMemory(),
+		IDs:    &sequentialIDs{},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	h := srv.Handler()
+	user := signupAndLogin(t, h, "ada@example.com")
+
+	var chirpIds []string
+	for _, body := range []string{"first", "second"} {
+		rec := doJSON(t, h, http.MethodPost, "/api/chirps", user.Token, map[string]string{"body": body})
+		var chirp chirpsResponseBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &chirp); err != nil || rec.Code != http.StatusCreated {
+			t.Fatalf("expected to chirp, got %d: %s", rec.Code, rec.Body.String())
+		}
+		chirpIds = append(chirpIds, chirp.ID)
+	}
+	rec := doJSON(t, h, http.MethodDelete, "/api/chirps/"+chirpIds[1], user.Token, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the chirp to be deleted, got %d", rec.Code)
+	}
+
+	events := srv.localStream.after(0, streamReplayLimit)
+	if len(events) != 3 || events[0].ID >= events[1].ID || events[1].ID >= events[2].ID {
+		t.Fatalf("expected three events in id order, got %+v", events)
+	}
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/stream", nil).WithContext(reqCtx)
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(events[0].ID, 10))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the stream to be served, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ids := streamEventIds(rec.Body.String()); fmt.Sprint(ids) != fmt.Sprint([]int64{events[2].ID}) {
+		t.Fatalf("expected only the deletion after the first event, got %v in %q", ids, rec.Body.String())
+	}
+}
+
+func TestNotificationGroupKey(t *testing.T) {
+	day := time.Date(2026, 10, 19, 23, 30, 0, 0, time.UTC)
+	follow := newNotification{userId: "u1", kind: notificationFollow, actorId: "u2"}
+	mention := newNotification{userId: "u1", kind: notificationMention, actorId: "u2", chirpId: "c1"}
+
+	if follow.groupKey(day) != follow.groupKey(day.Add(-time.Hour*23)) {
+		t.Fatal("expected follows on the same day to share a group")
+	}
+	if follow.groupKey(day) == follow.groupKey(day.Add(time.Hour)) {
+		t.Fatal("expected follows on the next day to start a new group")
+	}
+	// days are UTC whatever the server's zone
+	if key := follow.groupKey(day.In(time.FixedZone("UTC+2", 2*60*60))); key != "follow:2026-10-19" {
+		t.Fatalf("unexpected key %q", key)
+	}
+	if mention.groupKey(day) != "mention:c1" || mention.groupKey(day) != mention.groupKey(day.Add(time.Hour*48)) {
+		t.Fatal("expected mentions to be grouped by chirp only")
+	}
+}
+
+func TestNotificationGroupResponse(t *testing.T) {
+	group := newNotificationGroupResponseBody(database.GetNotificationGroupsRow{
+		GroupKey:          "follow:2026-10-19",
+		Type:              notificationFollow,
+		ActorCount:        3,
+		NotificationCount: 4,
+		Ids:               []string{"n4", "n3", "n2", "n1"},
+		ActorHandles:      []string{"carol", "bob", "carol", "dave"},
+	})
+	if fmt.Sprint(group.Actors) != "[carol bob dave]" {
+		t.Fatalf("expected each actor once, newest first, got %v", group.Actors)
+	}
+	if group.Summary != "carol and 2 others followed you" || group.GroupKey != "follow:2026-10-19" || group.NotificationCount != 4 {
+		t.Fatalf("unexpected group %+v", group)
+	}
+
+	tests := []struct {
+		kind       string
+		actors     []string
+		actorCount int64
+		want       string
+	}{
+		{notificationFollow, []string{"ada"}, 1, "ada followed you"},
+		{notificationFollow, []string{"ada", "bob"}, 2, "ada and bob followed you"},
+		{notificationMention, []string{"ada"}, 2, "ada and 1 other mentioned you in a chirp"},
+		{notificationMention, nil, 0, "someone mentioned you in a chirp"},
+		{notificationLike, []string{"carol@remote.test", "ada"}, 3, "carol@remote.test and 2 others liked your chirp"},
+	}
+	for _, tt := range tests {
+		if got := notificationSummary(tt.kind, tt.actors, tt.actorCount); got != tt.want {
+			t.Errorf("notificationSummary(%s, %v, %d) = %q, want %q", tt.kind, tt.actors, tt.actorCount, got, tt.want)
+		}
+	}
+}
+
+func TestBodyLimits(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	rec := doJSON(t, h, http.MethodPost, "/api/users", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": strings.Repeat("x", 70<<10),
+	})
+	if rec.Code != http.StatusRequestEntityTooLarge || errorCode(t, rec) != "payload_too_large" {
+		t.Fatalf("expected 413, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	s := New(Config{MaxBodyBytes: 10}, Deps{Store: store.NewMemory()})
+	read := func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		if err != nil {
+			response.Err(w, r, badBody(err, "error reading request body"))
+		}
+	}
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    int
+	}{
+		{"default limit", s.limitBodies(http.HandlerFunc(read)), http.StatusRequestEntityTooLarge},
+		{"raised limit", s.limitBodies(maxBody(100, read)), http.StatusOK},
+		{"lowered limit", s.limitBodies(maxBody(5, read)), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 50))))
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  store.NewMemory(),
+		IDs:    &sequentialIDs{},
+		Logger: slog.New(slog.NewJSONHandler(&logs, nil)),
+	})
+	h := srv.Handler()
+
+	doJSON(t, h, http.MethodPost, "/api/users", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	rec := doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	var loggedIn usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &loggedIn); err != nil {
+		t.Fatal(err)
+	}
+	logs.Reset()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(`{"body": "hello"}`))
+	req.Header.Set("Authorization", "Bearer "+loggedIn.Token)
+	req.Header.Set("X-Request-ID", "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry struct {
+		Msg       string `json:"msg"`
+		RequestID string `json:"request_id"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+		UserID    string `json:"user_id"`
+		Latency   *int64 `json:"latency"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON log line, got %q", logs.String())
+	}
+	if entry.Msg != "request" || entry.RequestID != "req-1" || entry.Route != "POST /api/chirps" ||
+		entry.Status != http.StatusCreated || entry.UserID != loggedIn.ID || entry.Latency == nil {
+		t.Fatalf("unexpected access log %s", logs.String())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	h, _ := newTestServer(t)
+
+	doJSON(t, h, http.MethodPost, "/api/users", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "wrong",
+	})
+	rec := doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "correct horse",
+	})
+	var loggedIn usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &loggedIn); err != nil {
+		t.Fatal(err)
+	}
+	doJSON(t, h, http.MethodPost, "/api/chirps", loggedIn.Token, map[string]string{"body": "hello"})
+	doJSON(t, h, http.MethodGet, "/nowhere", "", nil)
+
+	rec = doJSON(t, h, http.MethodGet, "/metrics", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	for _, want := range []string{
+		`chirpy_http_requests_total{code="201",route="POST /api/chirps"} 1`,
+		`chirpy_http_requests_total{code="404",route="unmatched"} 1`,
+		`chirpy_http_request_duration_seconds_count{route="POST /api/login"} 2`,
+		`chirpy_chirps_created_total 1`,
+		`chirpy_logins_total{result="failed"} 1`,
+		`chirpy_logins_total{result="succeeded"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, rec.Body.String())
+		}
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          string
+		metricsToken string
+		token        string
+		want         int
+	}{
+		{"open in dev", "dev", "", "", http.StatusOK},
+		{"refused outside dev", "prod", "", "", http.StatusForbidden},
+		{"missing token", "prod", "scrape", "", http.StatusUnauthorized},
+		{"wrong token", "dev", "scrape", "wrong", http.StatusUnauthorized},
+		{"valid token", "prod", "scrape", "scrape", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(Config{Env: tt.env, TokenSecret: "test-secret", MetricsToken: tt.metricsToken}, Deps{
+				Store:  store.NewMemory(),
+				Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+			})
+			rec := doJSON(t, srv.Handler(), http.MethodGet, "/metrics", tt.token, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifyInboxRequestChecksKeyHost(t *testing.T) {
+	s := New(Config{Env: "prod"}, Deps{Store: store.NewMemory()})
+
+	tests := []struct {
+		name    string
+		keyId   string
+		actorId string
+	}{
+		{"other host", "https://169.254.169.254/latest#main-key", "https://remote.example/users/ada"},
+		{"plain http", "http://remote.example/users/ada#main-key", "http://remote.example/users/ada"},
+		{"not a URL", "main-key", "https://remote.example/users/ada"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/ap/inbox", nil)
+			req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",headers="date",signature="c2ln"`, tt.keyId))
+
+			// the memory store has no Database, so reaching a fetch would panic
+			_, err := s.verifyInboxRequest(req, nil, tt.actorId)
+			if !errors.Is(err, activitypub.ErrInvalidSignature) {
+				t.Fatalf("expected the key to be refused before fetching, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMayBePublicHost(t *testing.T) {
+	tests := map[string]bool{
+		"hooks.example.com": true,
+		"93.184.216.34":     true,
+		"localhost":         false,
+		"127.0.0.1":         false,
+		"169.254.169.254":   false,
+		"10.0.0.8":          false,
+		"::1":               false,
+	}
+	for host, want := range tests {
+		if got := mayBePublicHost(host); got != want {
+			t.Errorf("mayBePublicHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	h, _ := newTestServer(t)
+	ada := signupAndLogin(t, h, "ada@example.com")
+	signupAndLogin(t, h, "grace@example.com")
+
+	rec := doJSON(t, h, http.MethodPatch, "/api/users", ada.Token, map[string]string{"password": "stolen token"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a change without current_password to be rejected, got %d", rec.Code)
+	}
+	rec = doJSON(t, h, http.MethodPatch, "/api/users", ada.Token, map[string]string{"email": "mallory@example.com", "current_password": "wrong"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a wrong current_password to be rejected, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, h, http.MethodPatch, "/api/users", ada.Token, map[string]string{"current_password": "correct horse"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an empty change to be rejected, got %d", rec.Code)
+	}
+
+	rec = doJSON(t, h, http.MethodPatch, "/api/users", ada.Token, map[string]string{
+		"email":            "grace@example.com",
+		"current_password": "correct horse",
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected a taken email to conflict, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(t, h, http.MethodPatch, "/api/users", ada.Token, map[string]string{
+		"email":            "ada@example.org",
+		"current_password": "correct horse",
+	})
+	var updated usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || updated.Email != "ada@example.com" || updated.PendingEmail != "ada@example.org" {
+		t.Fatalf("expected the new email to wait for verification, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(t, h, http.MethodPatch, "/api/users", ada.Token, map[string]string{
+		"password":         "battery staple",
+		"current_password": "correct horse",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(t, h, http.MethodPost, "/api/refresh", ada.RefreshToken, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a password change to revoke refresh tokens, got %d", rec.Code)
+	}
+	rec = doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "battery staple",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected to log in with the new password, got %d", rec.Code)
+	}
+}
+
+func TestPutUser(t *testing.T) {
+	h, _ := newTestServer(t)
+	ada := signupAndLogin(t, h, "ada@example.com")
+	signupAndLogin(t, h, "grace@example.com")
+
+	rec := doJSON(t, h, http.MethodPut, "/api/users", ada.Token, map[string]string{
+		"email":    "grace@example.com",
+		"password": "battery staple",
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected a taken email to conflict, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// the original contract: no current password, and the new email applies
+	// at once
+	rec = doJSON(t, h, http.MethodPut, "/api/users", ada.Token, map[string]string{
+		"email":    "ada@example.org",
+		"password": "battery staple",
+	})
+	var updated usersResponseBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || updated.Email != "ada@example.org" || updated.PendingEmail != "" {
+		t.Fatalf("expected the email to change, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if updated.Token != ada.Token || updated.RefreshToken != ada.RefreshToken {
+		t.Fatalf("expected the caller's tokens back, got %+v", updated)
+	}
+	if rec.Header().Get("Deprecation") != "true" {
+		t.Fatal("expected PUT /api/users to be marked deprecated")
+	}
+
+	rec = doJSON(t, h, http.MethodPost, "/api/login", "", map[string]string{
+		"email":    "ada@example.org",
+		"password": "battery staple",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected to log in with the new email and password, got %d", rec.Code)
+	}
+}
+
+func TestNextSubscription(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := time.Hour * 24
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	event := func(name string, periodEnd, cancelAt *time.Time) polkaWebhookBody {
+		var body polkaWebhookBody
+		body.Event = name
+		body.Data.PeriodEnd = periodEnd
+		body.Data.CancelAt = cancelAt
+		return body
+	}
+	current := database.Subscription{
+		ID:                 "sub",
+		Plan:               defaultSubscriptionPlan,
+		Status:             subscriptionStatusActive,
+		CurrentPeriodStart: now.Add(-day * 10),
+		CurrentPeriodEnd:   now.Add(day * 20),
+	}
+	pastDue := current
+	pastDue.Status = subscriptionStatusPastDue
+
+	tests := []struct {
+		name        string
+		current     database.Subscription
+		hasCurrent  bool
+		body        polkaWebhookBody
+		wantChanged bool
+		wantNew     bool
+		wantStatus  string
+		wantEnd     time.Time
+		wantCancel  bool
+	}{
+		{"upgrade without a subscription", database.Subscription{}, false, event("user.upgraded", nil, nil), true, true, subscriptionStatusActive, now.AddDate(0, 1, 0), false},
+		{"renewal extends the period", current, true, event("user.renewed", at(day*50), nil), true, false, subscriptionStatusActive, now.Add(day * 50), false},
+		{"late renewal keeps the longer period", current, true, event("user.renewed", at(day*5), nil), true, false, subscriptionStatusActive, now.Add(day * 20), false},
+		{"renewal clears past due", pastDue, true, event("user.renewed", at(day*50), nil), true, false, subscriptionStatusActive, now.Add(day * 50), false},
+		{"immediate downgrade", current, true, event("user.downgraded", nil, nil), true, false, subscriptionStatusCanceled, now.Add(day * 20), true},
+		{"scheduled downgrade", current, true, event("user.downgraded", nil, at(day*20)), true, false, subscriptionStatusActive, now.Add(day * 20), true},
+		{"downgrade without a subscription", database.Subscription{}, false, event("user.downgraded", nil, nil), false, false, "", time.Time{}, false},
+		{"payment failure", current, true, event("user.payment_failed", at(day*20), nil), true, false, subscriptionStatusPastDue, now.Add(day * 20), false},
+		{"stale payment failure", current, true, event("user.payment_failed", at(-day), nil), false, false, subscriptionStatusActive, now.Add(day * 20), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, changed := nextSubscription(tt.current, tt.hasCurrent, tt.body, now)
+			if changed != tt.wantChanged {
+				t.Fatalf("expected changed %v, got %v", tt.wantChanged, changed)
+			}
+			if !changed {
+				return
+			}
+			if (next.ID == "") != tt.wantNew || next.Status != tt.wantStatus || !next.CurrentPeriodEnd.Equal(tt.wantEnd) || next.CancelAt.Valid != tt.wantCancel {
+				t.Fatalf("unexpected subscription %+v", next)
+			}
+		})
+	}
+}
+
+// TestUserExportCoversSchema fails when a table is added without deciding
+// whether exports include it.
+func TestUserExportCoversSchema(t *testing.T) {
+	exported := map[string]string{}
+	for _, file := range userExportFiles {
+		for _, table := range file.tables {
+			if other, ok := exported[table]; ok {
+				t.Errorf("table %s is exported to both %s and %s", table, other, file.name)
+			}
+			exported[table] = file.name
+		}
+	}
+	for table := range userExportSkippedTables {
+		if _, ok := exported[table]; ok {
+			t.Errorf("table %s is both exported and skipped", table)
+		}
+	}
+
+	migrations, err := fs.Glob(schema.FS, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTable := regexp.MustCompile(`(?i)CREATE TABLE (\w+)`)
+	tables := map[string]bool{}
+	for _, name := range migrations {
+		data, err := fs.ReadFile(schema.FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose Down")
+		for _, m := range createTable.FindAllStringSubmatch(up, -1) {
+			tables[m[1]] = true
+		}
+	}
+	if len(tables) == 0 {
+		t.Fatal("no tables found in the schema")
+	}
+
+	for table := range tables {
+		_, isExported := exported[table]
+		_, isSkipped := userExportSkippedTables[table]
+		if !isExported && !isSkipped {
+			t.Errorf("table %s is neither in userExportFiles nor in userExportSkippedTables", table)
+		}
+	}
+	for table := range exported {
+		if !tables[table] {
+			t.Errorf("exported table %s isn't in the schema", table)
+		}
+	}
+	for table := range userExportSkippedTables {
+		if !tables[table] {
+			t.Errorf("skipped table %s isn't in the schema", table)
+		}
+	}
+}
+
+// exportsDB is a Database without any exports.
+type exportsDB struct {
+	*store.Memory
+	unimplementedQuerier
+}
+
+func (d *exportsDB) InTx(ctx context.Context, fn func(database.Querier) error) error {
+	return fn(d)
+}
+
+func (d *exportsDB) GetUserExport(ctx context.Context, id string) (database.UserExport, error) {
+	return database.UserExport{}, sql.ErrNoRows
+}
+
+func TestDownloadExportExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	srv := New(Config{Env: "dev", TokenSecret: "test-secret"}, Deps{
+		Store:  &exportsDB{Memory: store.NewMemory()},
+		Clock:  clock,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	h := srv.Handler()
+
+	expiresAt := clock.Now().Add(exportLinkTTL)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", auth.MakeDownloadSignature("e1", expiresAt, "test-secret"))
+	path := "/api/exports/e1?" + query.Encode()
+
+	// the link is checked against the server's clock, so it's still valid
+	// even though its expiry is long gone
+	rec := doJSON(t, h, "GET", path, "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a valid link to a missing export, got %d: %s", rec.Code, rec.Body)
+	}
+
+	clock.Advance(exportLinkTTL + time.Minute)
+	rec = doJSON(t, h, "GET", path, "", nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an expired link, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestValidateChirpBodyCountsCharacters(t *testing.T) {
+	// 140 characters, but 280 bytes
+	body := strings.Repeat("é", 140)
+	if _, err := validateChirpBody(body, 140); err != nil {
+		t.Fatalf("expected 140 two-byte characters to fit, got %v", err)
+	}
+	if _, err := validateChirpBody(body+"é", 140); err == nil {
+		t.Fatal("expected 141 characters to be too long")
+	}
+}
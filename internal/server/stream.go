@@ -0,0 +1,489 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/pubsub"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/gaba-bouliva/Chirpy/internal/webhooks"
+	"github.com/lib/pq"
+)
+
+// streamChannel is the PostgreSQL NOTIFY channel instances use to tell each
+// other about new stream events.
+const streamChannel = "chirpy_stream"
+
+// streamReplayLimit caps how many missed events a reconnecting client is
+// sent per query while catching up.
+const streamReplayLimit = 500
+
+// localStreamBacklog is how many recent events are kept for resuming streams
+// when there is no database to record them in.
+const localStreamBacklog = 1024
+
+var hashtagPattern = regexp.MustCompile(`#([a-z0-9_]+)`)
+
+// chirpHashtags returns the distinct lowercased hashtags in a chirp body,
+// matching the way the suggestions query extracts them.
+func chirpHashtags(body string) []string {
+	seen := map[string]bool{}
+	hashtags := []string{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(strings.ToLower(body), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			hashtags = append(hashtags, match[1])
+		}
+	}
+	return hashtags
+}
+
+type streamFilter struct {
+	authorId string
+	hashtag  string
+}
+
+// matches reports whether e belongs on a stream. Streams carry public chirp
+// events only; reactions and private events are for WebSockets.
+func (f streamFilter) matches(e pubsub.Event) bool {
+	if e.RecipientID != "" || (e.Type != webhooks.EventChirpCreated && e.Type != webhooks.EventChirpDeleted) {
+		return false
+	}
+	if f.authorId != "" && e.AuthorID != f.authorId {
+		return false
+	}
+	if f.hashtag == "" {
+		return true
+	}
+	for _, hashtag := range e.Hashtags {
+		if hashtag == f.hashtag {
+			return true
+		}
+	}
+	return false
+}
+
+// localStream numbers events and keeps the latest ones in memory, for stores
+// without the stream_events table. Ids continue from the time the server
+// started, so they keep increasing across restarts.
+type localStream struct {
+	mu     sync.Mutex
+	lastId int64
+	events []pubsub.Event
+}
+
+func newLocalStream(start time.Time) *localStream {
+	return &localStream{lastId: start.UnixMicro()}
+}
+
+// publish numbers e and publishes it to broker. Both happen under the lock,
+// so subscribers see events in id order.
+func (l *localStream) publish(broker *pubsub.Broker, e pubsub.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastId++
+	e.ID = l.lastId
+	l.events = append(l.events, e)
+	if len(l.events) > localStreamBacklog {
+		l.events = l.events[1:]
+	}
+	broker.Publish(e)
+}
+
+// after returns up to limit kept events with ids above afterId, oldest
+// first.
+func (l *localStream) after(afterId int64, limit int) []pubsub.Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := []pubsub.Event{}
+	for _, e := range l.events {
+		if e.ID > afterId && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func newStreamEvent(row database.StreamEvent) pubsub.Event {
+	return pubsub.Event{
+		ID:          row.ID,
+		Type:        row.Event,
+		AuthorID:    row.AuthorID,
+		RecipientID: row.RecipientID.String,
+		Hashtags:    row.Hashtags,
+		Data:        []byte(row.Payload),
+	}
+}
+
+// publishStreamEvent records a public chirp event so clients can resume from
+// it, notifies the other instances and publishes it to this instance's
+// subscribers. Failures are logged; streaming is best effort.
+func (s *Server) publishStreamEvent(ctx context.Context, event, authorId, chirpBody string, data any) {
+	s.appendStreamEvent(ctx, database.CreateStreamEventParams{
+		Event:    event,
+		AuthorID: authorId,
+		Hashtags: chirpHashtags(chirpBody),
+	}, data)
+}
+
+// publishUserEvent is publishStreamEvent for events only recipientId should
+// see, such as being followed or mentioned.
+func (s *Server) publishUserEvent(ctx context.Context, event, recipientId, actorId string, data any) {
+	s.appendStreamEvent(ctx, database.CreateStreamEventParams{
+		Event:       event,
+		AuthorID:    actorId,
+		RecipientID: sql.NullString{String: recipientId, Valid: true},
+		Hashtags:    []string{},
+	}, data)
+}
+
+// appendStreamEvent publishes an event, in id order: clients resume from
+// the last id they saw, so one must never be published after a higher one.
+// Without the database, events are only kept in memory.
+func (s *Server) appendStreamEvent(ctx context.Context, params database.CreateStreamEventParams, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		s.log(ctx).Error("appending stream event", "event", params.Event, "err", err)
+		return
+	}
+
+	if s.db == nil {
+		s.localStream.publish(s.broker, pubsub.Event{
+			Type:        params.Event,
+			AuthorID:    params.AuthorID,
+			RecipientID: params.RecipientID.String,
+			Hashtags:    params.Hashtags,
+			Data:        payload,
+		})
+		return
+	}
+
+	params.Payload = string(payload)
+	params.CreatedAt = s.clock.Now()
+
+	// with a listener, every instance publishes events as their
+	// notifications arrive, which is in commit order; without one, the
+	// lock keeps this instance's events in order
+	listening := s.databaseURL != ""
+	if !listening {
+		s.streamMu.Lock()
+		defer s.streamMu.Unlock()
+	}
+
+	var row database.StreamEvent
+	err = s.db.InTx(ctx, func(q database.Querier) error {
+		err := q.LockStreamEvents(ctx)
+		if err != nil {
+			return err
+		}
+		row, err = q.CreateStreamEvent(ctx, params)
+		if err != nil {
+			return err
+		}
+		return q.NotifyStreamEvent(ctx, strconv.FormatInt(row.ID, 10))
+	})
+	if err != nil {
+		s.log(ctx).Error("appending stream event", "event", params.Event, "err", err)
+		return
+	}
+
+	if !listening {
+		s.broker.Publish(newStreamEvent(row))
+	}
+}
+
+// publishStreamEventsAfter publishes the recorded events after afterId in
+// id order and returns the last id published.
+func (s *Server) publishStreamEventsAfter(ctx context.Context, afterId int64) (int64, error) {
+	for {
+		rows, err := s.db.GetStreamEventsAfter(ctx, database.GetStreamEventsAfterParams{
+			AfterID:   afterId,
+			MaxEvents: streamReplayLimit,
+		})
+		if err != nil {
+			return afterId, err
+		}
+		for _, row := range rows {
+			s.broker.Publish(newStreamEvent(row))
+			afterId = row.ID
+		}
+		if len(rows) < streamReplayLimit {
+			return afterId, nil
+		}
+	}
+}
+
+// runStreamListener publishes the events recorded by every instance,
+// including this one, to this instance's subscribers. Notifications only
+// say that there is something new: events are read back in id order from
+// the last one published, so none are skipped or sent twice.
+func (s *Server) runStreamListener(ctx context.Context, dbURL string) {
+	listener := pq.NewListener(dbURL, time.Second*10, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			s.log(ctx).Error("stream listener failed", "err", err)
+		}
+	})
+	defer listener.Close()
+
+	err := listener.Listen(streamChannel)
+	if err != nil {
+		s.log(ctx).Error("stream listener failed", "err", err)
+		return
+	}
+	lastId, err := s.db.GetLastStreamEventId(ctx)
+	if err != nil {
+		s.log(ctx).Error("stream listener failed", "err", err)
+		return
+	}
+
+	ticker := time.NewTicker(time.Second * 90)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			go listener.Ping()
+		case n := <-listener.Notify:
+			// a nil notification means the connection was re-established
+			// and catches up on anything sent meanwhile
+			if n != nil {
+				id, err := strconv.ParseInt(n.Extra, 10, 64)
+				if err == nil && id <= lastId {
+					continue
+				}
+			}
+			lastId, err = s.publishStreamEventsAfter(ctx, lastId)
+			if err != nil {
+				s.log(ctx).Error("stream listener failed", "err", err)
+			}
+		}
+	}
+}
+
+// runStreamEventPruner deletes stream events older than retention; clients
+// that were away longer than that start again from live events.
+func (s *Server) runStreamEventPruner(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.db.DeleteStreamEventsBefore(ctx, s.clock.Now().Add(-retention))
+			if err != nil {
+				s.log(ctx).Error("pruning stream events", "err", err)
+			}
+		}
+	}
+}
+
+// streamEventsAfter returns up to streamReplayLimit events after afterId,
+// oldest first, from the database or, without one, the local backlog.
+func (s *Server) streamEventsAfter(ctx context.Context, afterId int64) ([]pubsub.Event, error) {
+	if s.db == nil {
+		return s.localStream.after(afterId, streamReplayLimit), nil
+	}
+
+	rows, err := s.db.GetStreamEventsAfter(ctx, database.GetStreamEventsAfterParams{
+		AfterID:   afterId,
+		MaxEvents: streamReplayLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]pubsub.Event, len(rows))
+	for i, row := range rows {
+		events[i] = newStreamEvent(row)
+	}
+	return events, nil
+}
+
+// visibleChirps returns which of ids are chirps that still exist and
+// aren't hidden.
+func (s *Server) visibleChirps(ctx context.Context, ids []string) (map[string]bool, error) {
+	visible := map[string]bool{}
+	if s.db == nil {
+		for _, id := range ids {
+			_, err := s.store.GetChirpById(ctx, id)
+			if err == nil {
+				visible[id] = true
+			} else if err != sql.ErrNoRows {
+				return nil, err
+			}
+		}
+		return visible, nil
+	}
+
+	visibleIds, err := s.db.GetVisibleChirpIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range visibleIds {
+		visible[id] = true
+	}
+	return visible, nil
+}
+
+// dropDeletedChirps leaves out chirp.created events for chirps that have
+// since been deleted or hidden, so a replay doesn't bring them back.
+func (s *Server) dropDeletedChirps(ctx context.Context, events []pubsub.Event) ([]pubsub.Event, error) {
+	chirpIds := make([]string, len(events))
+	ids := []string{}
+	for i, e := range events {
+		if e.Type != webhooks.EventChirpCreated {
+			continue
+		}
+		var chirp chirpsResponseBody
+		if err := json.Unmarshal(e.Data, &chirp); err == nil && chirp.ID != "" {
+			chirpIds[i] = chirp.ID
+			ids = append(ids, chirp.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return events, nil
+	}
+
+	visible, err := s.visibleChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := []pubsub.Event{}
+	for i, e := range events {
+		if chirpIds[i] != "" && !visible[chirpIds[i]] {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept, nil
+}
+
+func writeStreamEvent(w http.ResponseWriter, e pubsub.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	filter := streamFilter{
+		authorId: r.URL.Query().Get("author_id"),
+		hashtag:  strings.ToLower(strings.TrimPrefix(r.URL.Query().Get("hashtag"), "#")),
+	}
+
+	var lastEventId int64
+	lastEventIdStr := r.Header.Get("Last-Event-ID")
+	if lastEventIdStr == "" {
+		lastEventIdStr = r.URL.Query().Get("last_event_id")
+	}
+	if lastEventIdStr != "" {
+		var err error
+		lastEventId, err = strconv.ParseInt(lastEventIdStr, 10, 64)
+		if err != nil || lastEventId < 0 {
+			response.Err(w, r, response.BadRequest("invalid Last-Event-ID"))
+			return
+		}
+	}
+
+	// subscribe before replaying so nothing published in between is lost
+	sub := s.broker.Subscribe(64)
+	defer s.broker.Unsubscribe(sub)
+
+	// streams outlive the server's read and write timeouts
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	// events are published in id order, so everything up to cursor has
+	// been sent once the replay is done
+	cursor := lastEventId
+	if lastEventId > 0 {
+		for {
+			events, err := s.streamEventsAfter(r.Context(), cursor)
+			if err != nil {
+				s.log(r.Context()).Error("replaying stream events", "err", err)
+				return
+			}
+			fetched := len(events)
+			if fetched > 0 {
+				cursor = events[fetched-1].ID
+			}
+			events, err = s.dropDeletedChirps(r.Context(), events)
+			if err != nil {
+				s.log(r.Context()).Error("replaying stream events", "err", err)
+				return
+			}
+			for _, e := range events {
+				if !filter.matches(e) {
+					continue
+				}
+				if err := writeStreamEvent(w, e); err != nil {
+					return
+				}
+			}
+			if fetched < streamReplayLimit {
+				break
+			}
+		}
+	}
+
+	if err := rc.Flush(); err != nil {
+		s.log(r.Context()).Warn("flushing stream", "err", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(time.Second * 15)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closing.Done():
+			return
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": keep-alive\n\n")
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			// a closed subscription means this client fell too far behind;
+			// ending the response makes it reconnect with Last-Event-ID
+			if !ok {
+				return
+			}
+			if e.ID <= cursor || !filter.matches(e) {
+				continue
+			}
+			err := writeStreamEvent(w, e)
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
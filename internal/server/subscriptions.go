@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+const (
+	subscriptionStatusActive   = "active"
+	subscriptionStatusPastDue  = "past_due"
+	subscriptionStatusCanceled = "canceled"
+	subscriptionStatusExpired  = "expired"
+
+	defaultSubscriptionPlan = "chirpy_red"
+)
+
+type subscriptionResponseBody struct {
+	ID                 string     `json:"id"`
+	Plan               string     `json:"plan"`
+	Status             string     `json:"status"`
+	CurrentPeriodStart time.Time  `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time  `json:"current_period_end"`
+	CancelAt           *time.Time `json:"cancel_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+func newSubscriptionResponseBody(sub database.Subscription) subscriptionResponseBody {
+	res := subscriptionResponseBody{
+		ID:                 sub.ID,
+		Plan:               sub.Plan,
+		Status:             sub.Status,
+		CurrentPeriodStart: sub.CurrentPeriodStart,
+		CurrentPeriodEnd:   sub.CurrentPeriodEnd,
+		CreatedAt:          sub.CreatedAt,
+	}
+	if sub.CancelAt.Valid {
+		res.CancelAt = &sub.CancelAt.Time
+	}
+	return res
+}
+
+// applySubscriptionEvent updates the user's subscription from a Polka billing
+// event and recomputes users.is_chirpy_red from it. The user's row is locked
+// for the transaction so concurrent events for one user apply one at a time.
+func (s *Server) applySubscriptionEvent(ctx context.Context, body polkaWebhookBody, now time.Time) error {
+	return s.db.InTx(ctx, func(q database.Querier) error {
+		userId, err := q.LockUserForBilling(ctx, body.Data.UserId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errPolkaUserNotFound
+			}
+			return err
+		}
+
+		current, err := q.GetLatestUserSubscription(ctx, userId)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		hasCurrent := err == nil && current.Status != subscriptionStatusExpired && current.Status != subscriptionStatusCanceled
+
+		next, changed := nextSubscription(current, hasCurrent, body, now)
+		switch {
+		case !changed:
+		case next.ID == "":
+			_, err = q.CreateSubscription(ctx, database.CreateSubscriptionParams{
+				ID:                 s.ids.NewID(),
+				UserID:             userId,
+				Plan:               next.Plan,
+				Status:             next.Status,
+				CurrentPeriodStart: next.CurrentPeriodStart,
+				CurrentPeriodEnd:   next.CurrentPeriodEnd,
+				CreatedAt:          now,
+				UpdatedAt:          now,
+			})
+		default:
+			_, err = q.UpdateSubscription(ctx, database.UpdateSubscriptionParams{
+				Plan:               next.Plan,
+				Status:             next.Status,
+				CurrentPeriodStart: next.CurrentPeriodStart,
+				CurrentPeriodEnd:   next.CurrentPeriodEnd,
+				CancelAt:           next.CancelAt,
+				UpdatedAt:          now,
+				ID:                 next.ID,
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		return q.SyncUserChirpyRed(ctx, database.SyncUserChirpyRedParams{
+			Now:    now,
+			UserID: userId,
+		})
+	})
+}
+
+// nextSubscription returns the subscription after body is applied to
+// current, which is only used when hasCurrent is set, and whether anything
+// changed. A result without an ID is a new subscription. Period ends only
+// ever move forward, so an older renewal delivered late can't shorten a
+// subscription.
+func nextSubscription(current database.Subscription, hasCurrent bool, body polkaWebhookBody, now time.Time) (database.Subscription, bool) {
+	plan := body.Data.Plan
+	if plan == "" {
+		plan = defaultSubscriptionPlan
+	}
+	periodStart := now
+	if body.Data.PeriodStart != nil {
+		periodStart = *body.Data.PeriodStart
+	}
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	if body.Data.PeriodEnd != nil {
+		periodEnd = *body.Data.PeriodEnd
+	}
+
+	switch body.Event {
+	case "user.upgraded", "user.renewed":
+		if !hasCurrent {
+			return database.Subscription{
+				Plan:               plan,
+				Status:             subscriptionStatusActive,
+				CurrentPeriodStart: periodStart,
+				CurrentPeriodEnd:   periodEnd,
+			}, true
+		}
+
+		next := current
+		if periodEnd.After(current.CurrentPeriodEnd) {
+			next.CurrentPeriodStart = periodStart
+			next.CurrentPeriodEnd = periodEnd
+		}
+		next.Plan = plan
+		next.Status = subscriptionStatusActive
+		next.CancelAt = sql.NullTime{}
+		return next, true
+
+	case "user.downgraded":
+		if !hasCurrent {
+			return current, false
+		}
+
+		// without an explicit cancel_at the downgrade takes effect immediately
+		cancelAt := now
+		if body.Data.CancelAt != nil {
+			cancelAt = *body.Data.CancelAt
+		}
+		next := current
+		if !cancelAt.After(now) {
+			next.Status = subscriptionStatusCanceled
+		}
+		next.CancelAt = sql.NullTime{Time: cancelAt, Valid: true}
+		return next, true
+
+	case "user.payment_failed":
+		if !hasCurrent {
+			return current, false
+		}
+		// a failure for a period that was since renewed is stale
+		if body.Data.PeriodEnd != nil && body.Data.PeriodEnd.Before(current.CurrentPeriodEnd) {
+			return current, false
+		}
+		next := current
+		next.Status = subscriptionStatusPastDue
+		return next, true
+	}
+	return current, false
+}
+
+// runSubscriptionJob expires subscriptions whose period ended or whose
+// cancellation date passed, and keeps users.is_chirpy_red in sync with them.
+func (s *Server) runSubscriptionJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := s.clock.Now()
+		expired, err := s.db.ExpireSubscriptions(ctx, now)
+		if err != nil {
+			s.log(ctx).Error("subscription job failed", "err", err)
+		} else if expired > 0 {
+			s.log(ctx).Info("subscription job expired subscriptions", "count", expired)
+		}
+
+		_, err = s.db.SyncAllUsersChirpyRed(ctx, now)
+		if err != nil {
+			s.log(ctx).Error("subscription job failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) handleGetUserSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	subs, err := s.db.GetUserSubscriptions(r.Context(), userId)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	results := []subscriptionResponseBody{}
+	for _, sub := range subs {
+		results = append(results, newSubscriptionResponseBody(sub))
+	}
+
+	response.JSON(w, http.StatusOK, results)
+}
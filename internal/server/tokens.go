@@ -0,0 +1,57 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+)
+
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	refreshToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Err(w, r, response.BadRequest("refresh authentication token not found"))
+		return
+	}
+
+	_, err = s.store.GetToken(r.Context(), refreshToken)
+	if err != nil {
+		response.Err(w, r, response.Unauthorized("authorized refresh token not found"))
+		return
+	}
+	params := database.RevokeTokenParams{
+		RevokedAt: sql.NullTime{Time: s.clock.Now(), Valid: true},
+		Token:     refreshToken,
+	}
+	err = s.store.RevokeToken(r.Context(), params)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	tokenStr, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		response.Err(w, r, response.BadRequest("authentication token not found"))
+		return
+	}
+
+	refreshToken, err := s.store.GetToken(r.Context(), tokenStr)
+	// if refreshToken.RevokeAt.Valid == false ( RevokeAt is null therefore refresToken is invalid)
+	if err != nil || s.clock.Now().After(refreshToken.ExpiresAt) || refreshToken.RevokedAt.Valid {
+		response.Err(w, r, response.Unauthorized("invalid token(s) provided"))
+		return
+	}
+
+	newJwtToken, err := auth.MakeJWT(refreshToken.UserID, s.tokenSecret, s.accessTTL)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{"token": newJwtToken})
+}
@@ -0,0 +1,521 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/response"
+	"github.com/gaba-bouliva/Chirpy/internal/store"
+)
+
+type usersResponseBody struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Email        string    `json:"email"`
+	Handle       string    `json:"handle"`
+	DisplayName  string    `json:"display_name"`
+	Bio          string    `json:"bio"`
+	AvatarURL    string    `json:"avatar_url"`
+	Website      string    `json:"website"`
+	PendingEmail string    `json:"pending_email,omitempty"`
+	Password     string    `json:"-"`
+	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	var reqBodyParams reqBody
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "invalid param(s) provided"))
+		return
+	}
+
+	user, err := s.store.GetUserByEmail(r.Context(), reqBodyParams.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			s.metrics.logins.WithLabelValues("failed").Inc()
+			response.Err(w, r, response.Unauthorized("invalid email or password provided"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	err = auth.CheckPasswordHash(reqBodyParams.Password, user.HashedPassword)
+	if err != nil {
+		s.metrics.logins.WithLabelValues("failed").Inc()
+		response.Err(w, r, response.Unauthorized("invalid email or password provided"))
+		return
+	}
+
+	// logging back in during the grace period cancels a pending account deletion
+	if user.DeleteAfter.Valid {
+		err = s.store.CancelUserDeletion(r.Context(), database.CancelUserDeletionParams{
+			UpdatedAt: s.clock.Now(),
+			ID:        user.ID,
+		})
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+	}
+
+	token, err := auth.MakeJWT(user.ID, s.tokenSecret, s.accessTTL)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	createRefreshTokenParams := database.CreateRefreshTokenParams{
+		Token:     refreshToken,
+		UpdatedAt: s.clock.Now(),
+		CreatedAt: s.clock.Now(),
+		UserID:    user.ID,
+		ExpiresAt: s.clock.Now().Add(s.refreshTTL),
+		RevokedAt: sql.NullTime{},
+	}
+
+	createdRefreshToken, err := s.store.CreateRefreshToken(r.Context(), createRefreshTokenParams)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	s.metrics.logins.WithLabelValues("succeeded").Inc()
+
+	res := newUsersResponseBody(user)
+	res.Token = token
+	res.RefreshToken = createdRefreshToken.Token
+
+	response.JSON(w, http.StatusOK, res)
+}
+
+func newUsersResponseBody(user database.User) usersResponseBody {
+	return usersResponseBody{
+		ID:           user.ID,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+		Email:        user.Email,
+		Handle:       user.Handle,
+		DisplayName:  user.DisplayName,
+		Bio:          user.Bio,
+		AvatarURL:    user.AvatarUrl,
+		Website:      user.Website,
+		IsChirpyRed:  user.IsChirpyRed,
+		PendingEmail: user.PendingEmail.String,
+	}
+}
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Email       string `json:"email"`
+		Password    string `json:"password"`
+		Handle      string `json:"handle"`
+		DisplayName string `json:"display_name"`
+	}
+
+	var reqBodyParams reqBody
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "invalid param(s) provided"))
+		return
+	}
+
+	password, err := auth.HashPassword(reqBodyParams.Password)
+	if err != nil {
+		if err.Error() == "invalid password provided" {
+			response.Err(w, r, response.BadRequest(err.Error()))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	userId := s.ids.NewID()
+
+	// users who don't pick a handle at sign up get a generated one they can change later
+	handle := strings.ToLower(reqBodyParams.Handle)
+	if handle == "" {
+		handle = "user_" + strings.ReplaceAll(userId, "-", "")[:10]
+	}
+	err = validateHandle(handle)
+	if err != nil {
+		response.Err(w, r, response.BadRequest(err.Error()))
+		return
+	}
+
+	createUserParam := database.CreateUserParams{
+		ID:             userId,
+		CreatedAt:      s.clock.Now(),
+		UpdatedAt:      s.clock.Now(),
+		Email:          reqBodyParams.Email,
+		HashedPassword: password,
+		Handle:         handle,
+		DisplayName:    strings.TrimSpace(reqBodyParams.DisplayName),
+	}
+
+	createdUser, err := s.store.CreateUser(r.Context(), createUserParam)
+	if err != nil {
+		if store.IsUniqueViolation(err) {
+			response.Err(w, r, response.Conflict("email or handle already taken"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	jsonData := newUsersResponseBody(createdUser)
+
+	response.JSON(w, http.StatusCreated, jsonData)
+}
+
+// handleUpdateUser is the original PUT /api/users: it replaces the email and
+// password at once, without the current password or email verification
+// that PATCH asks for. It's deprecated and kept for older clients.
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	p, _ := principalFrom(r.Context())
+	user := p.User
+
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</api/users>; rel="successor-version"`)
+
+	var reqBodyParams reqBody
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error proccessing request"))
+		return
+	}
+
+	hashedPwd, err := auth.HashPassword(reqBodyParams.Password)
+	if err != nil {
+		response.Err(w, r, response.BadRequest(err.Error()))
+		return
+	}
+
+	if reqBodyParams.Email != user.Email {
+		existing, err := s.store.GetUserByEmail(r.Context(), reqBodyParams.Email)
+		if err == nil && existing.ID != user.ID {
+			response.Err(w, r, response.Conflict(errEmailTaken.Error()))
+			return
+		}
+		if err != nil && err != sql.ErrNoRows {
+			response.Err(w, r, err)
+			return
+		}
+
+		// the email is set outright, replacing any change waiting for
+		// verification
+		_, err = s.store.SetUserPendingEmail(r.Context(), database.SetUserPendingEmailParams{
+			PendingEmail: sql.NullString{String: reqBodyParams.Email, Valid: true},
+			UpdatedAt:    s.clock.Now(),
+			ID:           user.ID,
+		})
+		if err == nil {
+			_, err = s.store.ConfirmUserEmail(r.Context(), database.ConfirmUserEmailParams{
+				UpdatedAt: s.clock.Now(),
+				ID:        user.ID,
+			})
+		}
+		if err != nil {
+			if store.IsUniqueViolation(err) {
+				response.Err(w, r, response.Conflict(errEmailTaken.Error()))
+				return
+			}
+			response.Err(w, r, err)
+			return
+		}
+	}
+
+	user, err = s.store.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		HashedPassword: hashedPwd,
+		UpdatedAt:      s.clock.Now(),
+		ID:             user.ID,
+	})
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	// older clients expect a refresh token back; hand them their newest one
+	tokens, err := s.store.GetAllUserTokens(r.Context(), user.ID)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	res := newUsersResponseBody(user)
+	res.Token = p.Token
+	for _, token := range tokens {
+		if !token.RevokedAt.Valid {
+			res.RefreshToken = token.Token
+		}
+	}
+
+	response.JSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handlePatchUser(w http.ResponseWriter, r *http.Request) {
+	// nil fields are left unchanged
+	type reqBody struct {
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+	}
+
+	user := currentUser(r)
+
+	var reqBodyParams reqBody
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reqBodyParams)
+	if err != nil {
+		response.Err(w, r, badBody(err, "error proccessing request"))
+		return
+	}
+
+	if reqBodyParams.Email == nil && reqBodyParams.Password == nil {
+		response.Err(w, r, response.BadRequest("no changes provided"))
+		return
+	}
+
+	err = auth.CheckPasswordHash(reqBodyParams.CurrentPassword, user.HashedPassword)
+	if err != nil {
+		response.Err(w, r, response.Unauthorized("current password is incorrect"))
+		return
+	}
+
+	if reqBodyParams.Password != nil {
+		hashedPwd, err := auth.HashPassword(*reqBodyParams.Password)
+		if err != nil {
+			response.Err(w, r, response.BadRequest(err.Error()))
+			return
+		}
+
+		user, err = s.store.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+			HashedPassword: hashedPwd,
+			UpdatedAt:      s.clock.Now(),
+			ID:             user.ID,
+		})
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+
+		// a password change signs out every other session
+		err = s.store.RevokeAllUserTokens(r.Context(), database.RevokeAllUserTokensParams{
+			RevokedAt: sql.NullTime{Time: s.clock.Now(), Valid: true},
+			UpdatedAt: s.clock.Now(),
+			UserID:    user.ID,
+		})
+		if err != nil {
+			response.Err(w, r, err)
+			return
+		}
+	}
+
+	if reqBodyParams.Email != nil && *reqBodyParams.Email != user.Email {
+		user, err = s.requestEmailChange(r.Context(), user, *reqBodyParams.Email)
+		if err != nil {
+			if errors.Is(err, errEmailTaken) {
+				response.Err(w, r, response.Conflict(err.Error()))
+				return
+			}
+			if errors.Is(err, errInvalidEmail) {
+				response.Err(w, r, response.BadRequest(err.Error()))
+				return
+			}
+			response.Err(w, r, err)
+			return
+		}
+	}
+
+	response.JSON(w, http.StatusOK, newUsersResponseBody(user))
+}
+
+var (
+	errEmailTaken   = errors.New("email already taken")
+	errInvalidEmail = errors.New("invalid email provided")
+)
+
+// requestEmailChange stores newEmail as pending and mails a verification link
+// to it. The account keeps its current email until the link is followed.
+func (s *Server) requestEmailChange(ctx context.Context, user database.User, newEmail string) (database.User, error) {
+	addr, err := mail.ParseAddress(newEmail)
+	if err != nil || addr.Address != newEmail {
+		return user, errInvalidEmail
+	}
+
+	existing, err := s.store.GetUserByEmail(ctx, newEmail)
+	if err == nil && existing.ID != user.ID {
+		return user, errEmailTaken
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return user, err
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return user, err
+	}
+
+	user, err = s.store.SetUserPendingEmail(ctx, database.SetUserPendingEmailParams{
+		PendingEmail:               sql.NullString{String: newEmail, Valid: true},
+		EmailVerificationToken:     sql.NullString{String: token, Valid: true},
+		EmailVerificationExpiresAt: sql.NullTime{Time: s.clock.Now().Add(time.Hour * 24), Valid: true},
+		UpdatedAt:                  s.clock.Now(),
+		ID:                         user.ID,
+	})
+	if err != nil {
+		return user, err
+	}
+
+	link := fmt.Sprintf("%s/api/users/verify-email?token=%s", s.baseURL, token)
+	body := fmt.Sprintf("Confirm your new Chirpy email address by opening this link within 24 hours:\n%s\n", link)
+	err = s.mailer.Send(ctx, newEmail, "Confirm your new Chirpy email", body)
+	if err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+func (s *Server) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Err(w, r, response.BadRequest("verification token not found"))
+		return
+	}
+
+	user, err := s.store.GetUserByEmailVerificationToken(r.Context(), sql.NullString{String: token, Valid: true})
+	if err != nil || !user.EmailVerificationExpiresAt.Valid || s.clock.Now().After(user.EmailVerificationExpiresAt.Time) {
+		if err != nil && err != sql.ErrNoRows {
+			s.log(r.Context()).Error("verifying email", "err", err)
+		}
+		response.Err(w, r, response.BadRequest("invalid or expired verification token"))
+		return
+	}
+
+	updatedUser, err := s.store.ConfirmUserEmail(r.Context(), database.ConfirmUserEmailParams{
+		UpdatedAt: s.clock.Now(),
+		ID:        user.ID,
+	})
+	if err != nil {
+		if store.IsUniqueViolation(err) {
+			response.Err(w, r, response.Conflict(errEmailTaken.Error()))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, newUsersResponseBody(updatedUser))
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	userId := currentUser(r).ID
+
+	now := s.clock.Now()
+	scheduleParams := database.ScheduleUserDeletionParams{
+		DeleteAfter: sql.NullTime{Time: now.Add(s.deletionGrace), Valid: true},
+		UpdatedAt:   now,
+		ID:          userId,
+	}
+
+	user, err := s.store.ScheduleUserDeletion(r.Context(), scheduleParams)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			response.Err(w, r, response.NotFound("user not found"))
+			return
+		}
+		response.Err(w, r, err)
+		return
+	}
+
+	revokeParams := database.RevokeAllUserTokensParams{
+		RevokedAt: sql.NullTime{Time: now, Valid: true},
+		UpdatedAt: now,
+		UserID:    user.ID,
+	}
+	err = s.store.RevokeAllUserTokens(r.Context(), revokeParams)
+	if err != nil {
+		response.Err(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusAccepted, map[string]time.Time{"delete_after": user.DeleteAfter.Time})
+}
+
+// runUserDeletionJob hard deletes accounts whose grace period has elapsed.
+// Chirps and refresh tokens are removed by the ON DELETE CASCADE on users.
+func (s *Server) runUserDeletionJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.deleteUsersDueForDeletion(ctx)
+		if err != nil {
+			s.log(ctx).Error("user deletion job failed", "err", err)
+		} else if deleted > 0 {
+			s.log(ctx).Info("user deletion job deleted users", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) deleteUsersDueForDeletion(ctx context.Context) (int64, error) {
+	now := sql.NullTime{Time: s.clock.Now(), Valid: true}
+
+	// export archives live outside the database, so they are deleted first;
+	// the cascade would take the rows that point at them
+	if s.db != nil {
+		exports, err := s.db.GetUserExportsDueForDeletion(ctx, now)
+		if err != nil {
+			return 0, err
+		}
+		for _, export := range exports {
+			err = s.deleteUserExport(ctx, export)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return s.store.DeleteUsersDueForDeletion(ctx, now)
+}
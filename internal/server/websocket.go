@@ -0,0 +1,387 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/auth"
+	"github.com/gaba-bouliva/Chirpy/internal/pubsub"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsChannelTimeline      = "timeline"
+	wsChannelNotifications = "notifications"
+)
+
+const (
+	wsWriteWait       = time.Second * 10
+	wsPongWait        = time.Second * 60
+	wsPingPeriod      = wsPongWait * 9 / 10
+	wsFolloweeRefresh = time.Minute
+	wsMaxMessageSize  = 4096
+	// wsSendBuffer is how many messages a client may fall behind by before
+	// it is disconnected.
+	wsSendBuffer = 64
+	// wsSessionCheck is how often an open connection's session is checked
+	// again, since it can expire or be revoked after the upgrade.
+	wsSessionCheck = time.Second * 30
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// clients authenticate with a bearer token rather than cookies, so a
+	// cross-origin page can't act on a user's behalf
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	// Token is a fresh access token, sent with "auth" before the one the
+	// connection was opened with expires.
+	Token string `json:"token"`
+}
+
+type wsServerMessage struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel,omitempty"`
+	ID      int64           `json:"id,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// wsClient is one authenticated WebSocket connection. The handler goroutine
+// reads client messages, writeLoop is the only writer of data frames and
+// pumpEvents filters broker events into the send queue.
+type wsClient struct {
+	server *Server
+	ctx    context.Context
+	userId string
+	conn   *websocket.Conn
+	send   chan wsServerMessage
+
+	// openedAt is what watchSession checks for revoked sessions against.
+	openedAt time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu        sync.Mutex
+	token     string
+	channels  map[string]bool
+	followees map[string]bool
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.log(r.Context()).Warn("websocket upgrade failed", "err", err)
+		return
+	}
+
+	c := &wsClient{
+		server:    s,
+		ctx:       r.Context(),
+		userId:    p.User.ID,
+		conn:      conn,
+		send:      make(chan wsServerMessage, wsSendBuffer),
+		token:     p.Token,
+		openedAt:  s.clock.Now(),
+		done:      make(chan struct{}),
+		channels:  map[string]bool{},
+		followees: map[string]bool{},
+	}
+
+	sub := s.broker.Subscribe(wsSendBuffer)
+	go c.writeLoop()
+	go c.pumpEvents(sub)
+	go c.watchSession()
+	go func() {
+		select {
+		case <-s.closing.Done():
+			c.close(websocket.CloseGoingAway, "server shutting down")
+		case <-c.done:
+		}
+	}()
+
+	c.readLoop()
+	s.broker.Unsubscribe(sub)
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+// close sends a close frame and tears the connection down. Only the first
+// call has any effect.
+func (c *wsClient) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+		c.conn.Close()
+	})
+}
+
+// enqueue queues msg for writing. A client whose queue is full is too slow
+// to keep up and is disconnected instead of blocking its producers.
+func (c *wsClient) enqueue(msg wsServerMessage) {
+	select {
+	case <-c.done:
+	case c.send <- msg:
+	default:
+		c.close(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+func (c *wsClient) readLoop() {
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.server.log(c.ctx).Warn("websocket closed unexpectedly", "err", err)
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var msg wsClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.enqueue(wsServerMessage{Type: "error", Message: "invalid message"})
+			continue
+		}
+		c.handleMessage(msg)
+	}
+}
+
+func (c *wsClient) handleMessage(msg wsClientMessage) {
+	switch msg.Type {
+	case "ping":
+		c.enqueue(wsServerMessage{Type: "pong"})
+	case "auth":
+		c.reauthenticate(msg.Token)
+	case "subscribe", "unsubscribe":
+		if msg.Channel != wsChannelTimeline && msg.Channel != wsChannelNotifications {
+			c.enqueue(wsServerMessage{Type: "error", Channel: msg.Channel, Message: "unknown channel"})
+			return
+		}
+		if msg.Type == "subscribe" && msg.Channel == wsChannelTimeline {
+			if err := c.refreshFollowees(); err != nil {
+				c.server.log(c.ctx).Error("loading websocket followees", "err", err)
+				c.enqueue(wsServerMessage{Type: "error", Channel: msg.Channel, Message: "could not load timeline"})
+				return
+			}
+		}
+
+		c.mu.Lock()
+		c.channels[msg.Channel] = msg.Type == "subscribe"
+		c.mu.Unlock()
+		c.enqueue(wsServerMessage{Type: msg.Type + "d", Channel: msg.Channel})
+	default:
+		c.enqueue(wsServerMessage{Type: "error", Message: "unknown message type"})
+	}
+}
+
+// reauthenticate swaps the connection's access token for token, which must
+// belong to the same user, so the connection can outlive the token it was
+// opened with.
+func (c *wsClient) reauthenticate(token string) {
+	userId, err := auth.ValidateJWT(token, c.server.tokenSecret)
+	if err != nil || userId != c.userId {
+		c.enqueue(wsServerMessage{Type: "error", Message: "invalid token"})
+		return
+	}
+
+	reason, err := c.server.wsSessionEnded(c.ctx, token, c.openedAt)
+	if err != nil {
+		c.server.log(c.ctx).Error("checking websocket session", "err", err)
+		c.enqueue(wsServerMessage{Type: "error", Message: "could not check token"})
+		return
+	}
+	if reason != "" {
+		c.close(websocket.ClosePolicyViolation, reason)
+		return
+	}
+
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+	c.enqueue(wsServerMessage{Type: "authenticated"})
+}
+
+func (c *wsClient) refreshFollowees() error {
+	ids, err := c.server.db.GetFolloweeIds(c.ctx, c.userId)
+	if err != nil {
+		return err
+	}
+
+	followees := map[string]bool{}
+	for _, id := range ids {
+		followees[id] = true
+	}
+
+	c.mu.Lock()
+	c.followees = followees
+	c.mu.Unlock()
+	return nil
+}
+
+// channelFor returns the channel e should be delivered on, or "" when the
+// client isn't subscribed to anything that includes it.
+func (c *wsClient) channelFor(e pubsub.Event) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e.RecipientID != "" {
+		if e.RecipientID == c.userId && c.channels[wsChannelNotifications] {
+			return wsChannelNotifications
+		}
+		return ""
+	}
+	if c.channels[wsChannelTimeline] && (e.AuthorID == c.userId || c.followees[e.AuthorID]) {
+		return wsChannelTimeline
+	}
+	return ""
+}
+
+func (c *wsClient) pumpEvents(sub *pubsub.Subscription) {
+	refresh := time.NewTicker(wsFolloweeRefresh)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-refresh.C:
+			c.mu.Lock()
+			subscribed := c.channels[wsChannelTimeline]
+			c.mu.Unlock()
+			if subscribed {
+				if err := c.refreshFollowees(); err != nil {
+					c.server.log(c.ctx).Error("loading websocket followees", "err", err)
+				}
+			}
+		case e, ok := <-sub.C:
+			// the broker drops subscribers that fall behind
+			if !ok {
+				c.close(websocket.CloseTryAgainLater, "client too slow")
+				return
+			}
+			channel := c.channelFor(e)
+			if channel == "" {
+				continue
+			}
+			c.enqueue(wsServerMessage{
+				Type:    "event",
+				Channel: channel,
+				ID:      e.ID,
+				Event:   e.Type,
+				Data:    e.Data,
+			})
+		}
+	}
+}
+
+// watchSession closes the connection with 1008 once the session it was
+// opened with is no longer valid.
+func (c *wsClient) watchSession() {
+	ticker := time.NewTicker(wsSessionCheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			token := c.token
+			c.mu.Unlock()
+			reason, err := c.server.wsSessionEnded(c.ctx, token, c.openedAt)
+			if err != nil {
+				c.server.log(c.ctx).Error("checking websocket session", "err", err)
+				continue
+			}
+			if reason != "" {
+				c.close(websocket.ClosePolicyViolation, reason)
+				return
+			}
+		}
+	}
+}
+
+// wsSessionEnded returns why a connection authenticated with token at since
+// must be closed, or "" while it may stay open. Clients keep a connection
+// past its token's expiry by sending a fresh one in an "auth" message.
+// Access tokens can't be revoked individually, so a user left with no live refresh token after one
+// was revoked since the connection opened, as when changing the password,
+// counts as signed out.
+func (s *Server) wsSessionEnded(ctx context.Context, token string, since time.Time) (string, error) {
+	userId, err := auth.ValidateJWT(token, s.tokenSecret)
+	if err != nil {
+		return "session expired", nil
+	}
+
+	user, err := s.store.GetUserById(ctx, userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "account deleted", nil
+		}
+		return "", err
+	}
+	if user.DeleteAfter.Valid {
+		return "account scheduled for deletion", nil
+	}
+
+	tokens, err := s.store.GetAllUserTokens(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	revoked := false
+	for _, t := range tokens {
+		if !t.RevokedAt.Valid && t.ExpiresAt.After(s.clock.Now()) {
+			return "", nil
+		}
+		if t.RevokedAt.Valid && !t.RevokedAt.Time.Before(since) {
+			revoked = true
+		}
+	}
+	if revoked {
+		return "session revoked", nil
+	}
+	return "", nil
+}
+
+func (c *wsClient) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ticker.C:
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			if err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/migrations"
+	"github.com/gaba-bouliva/Chirpy/internal/store"
+)
+
+// Store keeps users, chirps and refresh tokens in a SQLite database.
+type Store struct {
+	q  *Queries
+	db *sql.DB
+}
+
+var _ store.Store = (*Store)(nil)
+
+// Open opens the database named by a sqlite:// URL, e.g. sqlite://chirpy.db,
+// sqlite:///var/lib/chirpy.db or sqlite://:memory:, or a file: URI. If
+// migrate is set, it also brings the schema up to date.
+func Open(ctx context.Context, url string, migrate bool) (*Store, error) {
+	db, err := OpenDB(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if migrate {
+		provider, err := migrations.SQLite(db)
+		if err == nil {
+			_, err = provider.Up(ctx)
+		}
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("migrating sqlite schema: %w", err)
+		}
+	}
+	return &Store{q: New(utcDB{db}), db: db}, nil
+}
+
+// OpenDB opens the database named by url as Open does, without migrating it.
+func OpenDB(url string) (*sql.DB, error) {
+	dsn := url
+	if path, ok := strings.CutPrefix(url, "sqlite://"); ok {
+		dsn = "file:" + path
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	// _time_format=sqlite writes times as "2006-01-02 15:04:05.999999999-07:00",
+	// which sorts in time order once every time is in UTC
+	dsn += sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, and every connection to :memory: is a
+	// database of its own, so share one connection.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Stats returns the connection pool statistics.
+func (s *Store) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+// utcDB stores every time in UTC. SQLite keeps timestamps as text, so times
+// in different zones would neither compare nor sort correctly.
+type utcDB struct {
+	db *sql.DB
+}
+
+func utc(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case sql.NullTime:
+			args[i] = sql.NullTime{Time: v.Time.UTC(), Valid: v.Valid}
+		}
+	}
+	return args
+}
+
+func (u utcDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return u.db.ExecContext(ctx, query, utc(args)...)
+}
+
+func (u utcDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return u.db.PrepareContext(ctx, query)
+}
+
+func (u utcDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return u.db.QueryContext(ctx, query, utc(args)...)
+}
+
+func (u utcDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return u.db.QueryRowContext(ctx, query, utc(args)...)
+}
+
+// writeErr reports duplicate values as store.ErrUniqueViolation.
+func writeErr(err error) error {
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %v", store.ErrUniqueViolation, err)
+	}
+	return err
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user, err := s.q.CreateUser(ctx, CreateUserParams(arg))
+	return database.User(user), writeErr(err)
+}
+
+func (s *Store) GetUserById(ctx context.Context, id string) (database.User, error) {
+	user, err := s.q.GetUserById(ctx, id)
+	return database.User(user), err
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	user, err := s.q.GetUserByEmail(ctx, email)
+	return database.User(user), err
+}
+
+func (s *Store) GetUserByHandle(ctx context.Context, handle string) (database.User, error) {
+	user, err := s.q.GetUserByHandle(ctx, handle)
+	return database.User(user), err
+}
+
+func (s *Store) GetUserByEmailVerificationToken(ctx context.Context, emailVerificationToken sql.NullString) (database.User, error) {
+	user, err := s.q.GetUserByEmailVerificationToken(ctx, emailVerificationToken)
+	return database.User(user), err
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	user, err := s.q.UpdateUserPassword(ctx, UpdateUserPasswordParams(arg))
+	return database.User(user), err
+}
+
+func (s *Store) UpdateUserProfile(ctx context.Context, arg database.UpdateUserProfileParams) (database.User, error) {
+	user, err := s.q.UpdateUserProfile(ctx, UpdateUserProfileParams(arg))
+	return database.User(user), writeErr(err)
+}
+
+func (s *Store) UpdateUserSetChirpyRed(ctx context.Context, arg database.UpdateUserSetChirpyRedParams) (int64, error) {
+	return s.q.UpdateUserSetChirpyRed(ctx, UpdateUserSetChirpyRedParams(arg))
+}
+
+func (s *Store) SetUserPendingEmail(ctx context.Context, arg database.SetUserPendingEmailParams) (database.User, error) {
+	user, err := s.q.SetUserPendingEmail(ctx, SetUserPendingEmailParams(arg))
+	return database.User(user), writeErr(err)
+}
+
+func (s *Store) ConfirmUserEmail(ctx context.Context, arg database.ConfirmUserEmailParams) (database.User, error) {
+	user, err := s.q.ConfirmUserEmail(ctx, ConfirmUserEmailParams(arg))
+	return database.User(user), writeErr(err)
+}
+
+func (s *Store) ScheduleUserDeletion(ctx context.Context, arg database.ScheduleUserDeletionParams) (database.User, error) {
+	user, err := s.q.ScheduleUserDeletion(ctx, ScheduleUserDeletionParams(arg))
+	return database.User(user), err
+}
+
+func (s *Store) CancelUserDeletion(ctx context.Context, arg database.CancelUserDeletionParams) error {
+	return s.q.CancelUserDeletion(ctx, CancelUserDeletionParams(arg))
+}
+
+func (s *Store) DeleteUsersDueForDeletion(ctx context.Context, deleteAfter sql.NullTime) (int64, error) {
+	return s.q.DeleteUsersDueForDeletion(ctx, deleteAfter)
+}
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	chirp, err := s.q.CreateChirp(ctx, CreateChirpParams(arg))
+	return database.Chirp(chirp), writeErr(err)
+}
+
+func (s *Store) GetChirpById(ctx context.Context, id string) (database.GetChirpByIdRow, error) {
+	row, err := s.q.GetChirpById(ctx, id)
+	return database.GetChirpByIdRow{
+		Chirp:       database.Chirp(row.Chirp),
+		Handle:      row.Handle,
+		DisplayName: row.DisplayName,
+	}, err
+}
+
+func (s *Store) GetAllChirps(ctx context.Context) ([]database.GetAllChirpsRow, error) {
+	rows, err := s.q.GetAllChirps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var items []database.GetAllChirpsRow
+	for _, row := range rows {
+		items = append(items, database.GetAllChirpsRow{
+			Chirp:       database.Chirp(row.Chirp),
+			Handle:      row.Handle,
+			DisplayName: row.DisplayName,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) GetAllUserChirps(ctx context.Context, userID string) ([]database.GetAllUserChirpsRow, error) {
+	rows, err := s.q.GetAllUserChirps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var items []database.GetAllUserChirpsRow
+	for _, row := range rows {
+		items = append(items, database.GetAllUserChirpsRow{
+			Chirp:       database.Chirp(row.Chirp),
+			Handle:      row.Handle,
+			DisplayName: row.DisplayName,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) GetUserChirpsPage(ctx context.Context, arg database.GetUserChirpsPageParams) ([]database.GetUserChirpsPageRow, error) {
+	rows, err := s.q.GetUserChirpsPage(ctx, GetUserChirpsPageParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	var items []database.GetUserChirpsPageRow
+	for _, row := range rows {
+		items = append(items, database.GetUserChirpsPageRow{
+			Chirp:       database.Chirp(row.Chirp),
+			Handle:      row.Handle,
+			DisplayName: row.DisplayName,
+		})
+	}
+	return items, nil
+}
+
+// GetUserChirpsSummary takes two queries: SQLite loses the column type of
+// max(updated_at), so it would come back as text.
+func (s *Store) GetUserChirpsSummary(ctx context.Context, userID string) (database.GetUserChirpsSummaryRow, error) {
+	count, err := s.q.CountUserChirps(ctx, userID)
+	if err != nil {
+		return database.GetUserChirpsSummaryRow{}, err
+	}
+	lastUpdated, err := lastUpdatedOrEpoch(s.q.GetUserChirpsLastUpdated(ctx, userID))
+	return database.GetUserChirpsSummaryRow{ChirpCount: count, LastUpdated: lastUpdated}, err
+}
+
+func (s *Store) GetHashtagChirpsPage(ctx context.Context, arg database.GetHashtagChirpsPageParams) ([]database.GetHashtagChirpsPageRow, error) {
+	rows, err := s.q.GetHashtagChirpsPage(ctx, GetHashtagChirpsPageParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	var items []database.GetHashtagChirpsPageRow
+	for _, row := range rows {
+		items = append(items, database.GetHashtagChirpsPageRow{
+			Chirp:       database.Chirp(row.Chirp),
+			Handle:      row.Handle,
+			DisplayName: row.DisplayName,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) GetHashtagChirpsSummary(ctx context.Context, hashtag string) (database.GetHashtagChirpsSummaryRow, error) {
+	count, err := s.q.CountHashtagChirps(ctx, hashtag)
+	if err != nil {
+		return database.GetHashtagChirpsSummaryRow{}, err
+	}
+	lastUpdated, err := lastUpdatedOrEpoch(s.q.GetHashtagChirpsLastUpdated(ctx, hashtag))
+	return database.GetHashtagChirpsSummaryRow{ChirpCount: count, LastUpdated: lastUpdated}, err
+}
+
+// lastUpdatedOrEpoch matches PostgreSQL's COALESCE(max(updated_at), 'epoch')
+// when there are no chirps.
+func lastUpdatedOrEpoch(t time.Time, err error) (time.Time, error) {
+	if err == sql.ErrNoRows {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	return t, err
+}
+
+func (s *Store) UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error) {
+	chirp, err := s.q.UpdateChirpBody(ctx, UpdateChirpBodyParams(arg))
+	return database.Chirp(chirp), err
+}
+
+func (s *Store) DeleteChirpById(ctx context.Context, id string) error {
+	return s.q.DeleteChirpById(ctx, id)
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	token, err := s.q.CreateRefreshToken(ctx, CreateRefreshTokenParams(arg))
+	return database.RefreshToken(token), writeErr(err)
+}
+
+func (s *Store) GetToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	refreshToken, err := s.q.GetToken(ctx, token)
+	return database.RefreshToken(refreshToken), err
+}
+
+func (s *Store) GetAllUserTokens(ctx context.Context, userID string) ([]database.RefreshToken, error) {
+	tokens, err := s.q.GetAllUserTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var items []database.RefreshToken
+	for _, token := range tokens {
+		items = append(items, database.RefreshToken(token))
+	}
+	return items, nil
+}
+
+func (s *Store) RevokeToken(ctx context.Context, arg database.RevokeTokenParams) error {
+	return s.q.RevokeToken(ctx, RevokeTokenParams(arg))
+}
+
+func (s *Store) RevokeAllUserTokens(ctx context.Context, arg database.RevokeAllUserTokensParams) error {
+	return s.q.RevokeAllUserTokens(ctx, RevokeAllUserTokensParams(arg))
+}
@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gaba-bouliva/Chirpy/internal/store"
+	"github.com/gaba-bouliva/Chirpy/internal/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := Open(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "chirpy.db"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestOpenInMemory(t *testing.T) {
+	s, err := Open(context.Background(), "sqlite://:memory:", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	chirps, err := s.GetAllChirps(context.Background())
+	if err != nil || len(chirps) != 0 {
+		t.Fatalf("expected an empty migrated database, got %d chirps (%v)", len(chirps), err)
+	}
+}
+
+func TestOpenWithoutMigrating(t *testing.T) {
+	s, err := Open(context.Background(), "sqlite://:memory:", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.GetAllChirps(context.Background()); err == nil {
+		t.Fatal("expected an unmigrated database to have no chirps table")
+	}
+}
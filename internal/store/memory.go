@@ -0,0 +1,491 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+)
+
+// Memory is a Store that keeps everything in memory, for tests and for
+// running Chirpy without a database. It is safe for concurrent use.
+type Memory struct {
+	mu     sync.RWMutex
+	users  map[string]database.User
+	chirps map[string]database.Chirp
+	tokens map[string]database.RefreshToken
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:  map[string]database.User{},
+		chirps: map[string]database.Chirp{},
+		tokens: map[string]database.RefreshToken{},
+	}
+}
+
+// checkUnique rejects user changes that would duplicate another user's
+// email, handle or verification token. Callers must hold m.mu.
+func (m *Memory) checkUnique(user database.User) error {
+	for _, other := range m.users {
+		if other.ID == user.ID {
+			continue
+		}
+		if other.Email == user.Email || other.Handle == user.Handle {
+			return ErrUniqueViolation
+		}
+		if user.EmailVerificationToken.Valid && other.EmailVerificationToken == user.EmailVerificationToken {
+			return ErrUniqueViolation
+		}
+	}
+	return nil
+}
+
+// updateUser applies update to the user with the given id and stores the
+// result. Callers must hold m.mu for writing.
+func (m *Memory) updateUser(id string, update func(*database.User) bool) (database.User, error) {
+	user, ok := m.users[id]
+	if !ok || !update(&user) {
+		return database.User{}, sql.ErrNoRows
+	}
+	if err := m.checkUnique(user); err != nil {
+		return database.User{}, err
+	}
+	m.users[id] = user
+	return user, nil
+}
+
+// deleteUser removes a user with their chirps and refresh tokens, as the
+// foreign keys cascade in PostgreSQL. Callers must hold m.mu for writing.
+func (m *Memory) deleteUser(id string) {
+	delete(m.users, id)
+	for chirpId, chirp := range m.chirps {
+		if chirp.UserID == id {
+			delete(m.chirps, chirpId)
+		}
+	}
+	for token, refreshToken := range m.tokens {
+		if refreshToken.UserID == id {
+			delete(m.tokens, token)
+		}
+	}
+}
+
+func (m *Memory) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.ID]; ok {
+		return database.User{}, ErrUniqueViolation
+	}
+	user := database.User{
+		ID:             arg.ID,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		CreatedAt:      arg.CreatedAt,
+		UpdatedAt:      arg.UpdatedAt,
+		Handle:         arg.Handle,
+		DisplayName:    arg.DisplayName,
+	}
+	if err := m.checkUnique(user); err != nil {
+		return database.User{}, err
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *Memory) GetUserById(ctx context.Context, id string) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (m *Memory) findUser(match func(database.User) bool) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	return m.findUser(func(user database.User) bool {
+		return user.Email == email
+	})
+}
+
+func (m *Memory) GetUserByHandle(ctx context.Context, handle string) (database.User, error) {
+	return m.findUser(func(user database.User) bool {
+		return user.Handle == handle
+	})
+}
+
+func (m *Memory) GetUserByEmailVerificationToken(ctx context.Context, emailVerificationToken sql.NullString) (database.User, error) {
+	return m.findUser(func(user database.User) bool {
+		return emailVerificationToken.Valid && user.EmailVerificationToken == emailVerificationToken
+	})
+}
+
+func (m *Memory) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(user *database.User) bool {
+		user.HashedPassword = arg.HashedPassword
+		user.UpdatedAt = arg.UpdatedAt
+		return true
+	})
+}
+
+func (m *Memory) UpdateUserProfile(ctx context.Context, arg database.UpdateUserProfileParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(user *database.User) bool {
+		user.Handle = arg.Handle
+		user.DisplayName = arg.DisplayName
+		user.Bio = arg.Bio
+		user.AvatarUrl = arg.AvatarUrl
+		user.Website = arg.Website
+		user.UpdatedAt = arg.UpdatedAt
+		return true
+	})
+}
+
+func (m *Memory) UpdateUserSetChirpyRed(ctx context.Context, arg database.UpdateUserSetChirpyRedParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.updateUser(arg.ID, func(user *database.User) bool {
+		user.IsChirpyRed = arg.IsChirpyRed
+		return true
+	})
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (m *Memory) SetUserPendingEmail(ctx context.Context, arg database.SetUserPendingEmailParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(user *database.User) bool {
+		user.PendingEmail = arg.PendingEmail
+		user.EmailVerificationToken = arg.EmailVerificationToken
+		user.EmailVerificationExpiresAt = arg.EmailVerificationExpiresAt
+		user.UpdatedAt = arg.UpdatedAt
+		return true
+	})
+}
+
+func (m *Memory) ConfirmUserEmail(ctx context.Context, arg database.ConfirmUserEmailParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(user *database.User) bool {
+		if !user.PendingEmail.Valid {
+			return false
+		}
+		user.Email = user.PendingEmail.String
+		user.PendingEmail = sql.NullString{}
+		user.EmailVerificationToken = sql.NullString{}
+		user.EmailVerificationExpiresAt = sql.NullTime{}
+		user.UpdatedAt = arg.UpdatedAt
+		return true
+	})
+}
+
+func (m *Memory) ScheduleUserDeletion(ctx context.Context, arg database.ScheduleUserDeletionParams) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUser(arg.ID, func(user *database.User) bool {
+		user.DeleteAfter = arg.DeleteAfter
+		user.UpdatedAt = arg.UpdatedAt
+		return true
+	})
+}
+
+func (m *Memory) CancelUserDeletion(ctx context.Context, arg database.CancelUserDeletionParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.updateUser(arg.ID, func(user *database.User) bool {
+		user.DeleteAfter = sql.NullTime{}
+		user.UpdatedAt = arg.UpdatedAt
+		return true
+	})
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+func (m *Memory) DeleteUsersDueForDeletion(ctx context.Context, deleteAfter sql.NullTime) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !deleteAfter.Valid {
+		return 0, nil
+	}
+	var deleted int64
+	for id, user := range m.users {
+		if user.DeleteAfter.Valid && !user.DeleteAfter.Time.After(deleteAfter.Time) {
+			m.deleteUser(id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *Memory) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.chirps[arg.ID]; ok {
+		return database.Chirp{}, ErrUniqueViolation
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	chirp := database.Chirp{
+		ID:        arg.ID,
+		Body:      arg.Body,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+	}
+	m.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+// chirpRow is the shape shared by the chirp queries that join the author.
+type chirpRow struct {
+	Chirp       database.Chirp
+	Handle      string
+	DisplayName string
+}
+
+// listChirps returns the matching chirps of users not pending deletion,
+// oldest first.
+func (m *Memory) listChirps(match func(database.Chirp) bool) []chirpRow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rows []chirpRow
+	for _, chirp := range m.chirps {
+		author, ok := m.users[chirp.UserID]
+		if !ok || author.DeleteAfter.Valid || !match(chirp) {
+			continue
+		}
+		rows = append(rows, chirpRow{Chirp: chirp, Handle: author.Handle, DisplayName: author.DisplayName})
+	}
+	slices.SortFunc(rows, func(a, b chirpRow) int {
+		return cmp.Or(a.Chirp.CreatedAt.Compare(b.Chirp.CreatedAt), cmp.Compare(a.Chirp.ID, b.Chirp.ID))
+	})
+	return rows
+}
+
+func (m *Memory) GetChirpById(ctx context.Context, id string) (database.GetChirpByIdRow, error) {
+	rows := m.listChirps(func(chirp database.Chirp) bool {
+		return chirp.ID == id
+	})
+	if len(rows) == 0 {
+		return database.GetChirpByIdRow{}, sql.ErrNoRows
+	}
+	return database.GetChirpByIdRow(rows[0]), nil
+}
+
+func (m *Memory) GetAllChirps(ctx context.Context) ([]database.GetAllChirpsRow, error) {
+	var items []database.GetAllChirpsRow
+	for _, row := range m.listChirps(func(database.Chirp) bool { return true }) {
+		items = append(items, database.GetAllChirpsRow(row))
+	}
+	return items, nil
+}
+
+func (m *Memory) GetAllUserChirps(ctx context.Context, userID string) ([]database.GetAllUserChirpsRow, error) {
+	var items []database.GetAllUserChirpsRow
+	for _, row := range m.listChirps(func(chirp database.Chirp) bool { return chirp.UserID == userID }) {
+		items = append(items, database.GetAllUserChirpsRow(row))
+	}
+	return items, nil
+}
+
+// chirpsPage returns one page of rows, newest first.
+func chirpsPage(rows []chirpRow, limit, offset int32) []chirpRow {
+	slices.Reverse(rows)
+	start := min(int(offset), len(rows))
+	end := min(start+int(limit), len(rows))
+	return rows[start:end]
+}
+
+// chirpsSummary counts rows and finds the latest update, which is the epoch
+// when there are none.
+func chirpsSummary(rows []chirpRow) (int64, time.Time) {
+	lastUpdated := time.Unix(0, 0).UTC()
+	for _, row := range rows {
+		if row.Chirp.UpdatedAt.After(lastUpdated) {
+			lastUpdated = row.Chirp.UpdatedAt
+		}
+	}
+	return int64(len(rows)), lastUpdated
+}
+
+func (m *Memory) GetUserChirpsPage(ctx context.Context, arg database.GetUserChirpsPageParams) ([]database.GetUserChirpsPageRow, error) {
+	rows := m.listChirps(func(chirp database.Chirp) bool { return chirp.UserID == arg.UserID })
+
+	var items []database.GetUserChirpsPageRow
+	for _, row := range chirpsPage(rows, arg.Limit, arg.Offset) {
+		items = append(items, database.GetUserChirpsPageRow(row))
+	}
+	return items, nil
+}
+
+func (m *Memory) GetUserChirpsSummary(ctx context.Context, userID string) (database.GetUserChirpsSummaryRow, error) {
+	rows := m.listChirps(func(chirp database.Chirp) bool { return chirp.UserID == userID })
+
+	count, lastUpdated := chirpsSummary(rows)
+	return database.GetUserChirpsSummaryRow{ChirpCount: count, LastUpdated: lastUpdated}, nil
+}
+
+var hashtagPattern = regexp.MustCompile(`#([a-z0-9_]+)`)
+
+// listHashtagChirps matches hashtags the way chirp_hashtags does in
+// PostgreSQL.
+func (m *Memory) listHashtagChirps(hashtag string) []chirpRow {
+	return m.listChirps(func(chirp database.Chirp) bool {
+		for _, match := range hashtagPattern.FindAllStringSubmatch(strings.ToLower(chirp.Body), -1) {
+			if match[1] == hashtag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (m *Memory) GetHashtagChirpsPage(ctx context.Context, arg database.GetHashtagChirpsPageParams) ([]database.GetHashtagChirpsPageRow, error) {
+	var items []database.GetHashtagChirpsPageRow
+	for _, row := range chirpsPage(m.listHashtagChirps(arg.Hashtag), arg.Limit, arg.Offset) {
+		items = append(items, database.GetHashtagChirpsPageRow(row))
+	}
+	return items, nil
+}
+
+func (m *Memory) GetHashtagChirpsSummary(ctx context.Context, hashtag string) (database.GetHashtagChirpsSummaryRow, error) {
+	count, lastUpdated := chirpsSummary(m.listHashtagChirps(hashtag))
+	return database.GetHashtagChirpsSummaryRow{ChirpCount: count, LastUpdated: lastUpdated}, nil
+}
+
+func (m *Memory) UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chirp, ok := m.chirps[arg.ID]
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	chirp.Body = arg.Body
+	chirp.UpdatedAt = arg.UpdatedAt
+	m.chirps[chirp.ID] = chirp
+	return chirp, nil
+}
+
+func (m *Memory) DeleteChirpById(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.chirps, id)
+	return nil
+}
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tokens[arg.Token]; ok {
+		return database.RefreshToken{}, ErrUniqueViolation
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	token := database.RefreshToken(arg)
+	m.tokens[token.Token] = token
+	return token, nil
+}
+
+func (m *Memory) GetToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	refreshToken, ok := m.tokens[token]
+	if !ok {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	return refreshToken, nil
+}
+
+func (m *Memory) GetAllUserTokens(ctx context.Context, userID string) ([]database.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var items []database.RefreshToken
+	for _, token := range m.tokens {
+		if token.UserID == userID {
+			items = append(items, token)
+		}
+	}
+	slices.SortFunc(items, func(a, b database.RefreshToken) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.Token, b.Token))
+	})
+	return items, nil
+}
+
+func (m *Memory) RevokeToken(ctx context.Context, arg database.RevokeTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[arg.Token]
+	if !ok {
+		return nil
+	}
+	token.RevokedAt = arg.RevokedAt
+	token.UpdatedAt = arg.UpdatedAt
+	m.tokens[token.Token] = token
+	return nil
+}
+
+func (m *Memory) RevokeAllUserTokens(ctx context.Context, arg database.RevokeAllUserTokensParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, token := range m.tokens {
+		if token.UserID == arg.UserID && !token.RevokedAt.Valid {
+			token.RevokedAt = arg.RevokedAt
+			token.UpdatedAt = arg.UpdatedAt
+			m.tokens[key] = token
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package store defines the data every Chirpy deployment needs (users,
+// chirps and refresh tokens) and the backends that can hold it.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+type Users interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUserById(ctx context.Context, id string) (database.User, error)
+	GetUserByEmail(ctx context.Context, email string) (database.User, error)
+	GetUserByHandle(ctx context.Context, handle string) (database.User, error)
+	GetUserByEmailVerificationToken(ctx context.Context, emailVerificationToken sql.NullString) (database.User, error)
+	UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error)
+	UpdateUserProfile(ctx context.Context, arg database.UpdateUserProfileParams) (database.User, error)
+	UpdateUserSetChirpyRed(ctx context.Context, arg database.UpdateUserSetChirpyRedParams) (int64, error)
+	SetUserPendingEmail(ctx context.Context, arg database.SetUserPendingEmailParams) (database.User, error)
+	ConfirmUserEmail(ctx context.Context, arg database.ConfirmUserEmailParams) (database.User, error)
+	ScheduleUserDeletion(ctx context.Context, arg database.ScheduleUserDeletionParams) (database.User, error)
+	CancelUserDeletion(ctx context.Context, arg database.CancelUserDeletionParams) error
+	DeleteUsersDueForDeletion(ctx context.Context, deleteAfter sql.NullTime) (int64, error)
+}
+
+type Chirps interface {
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	GetChirpById(ctx context.Context, id string) (database.GetChirpByIdRow, error)
+	GetAllChirps(ctx context.Context) ([]database.GetAllChirpsRow, error)
+	GetAllUserChirps(ctx context.Context, userID string) ([]database.GetAllUserChirpsRow, error)
+	GetUserChirpsPage(ctx context.Context, arg database.GetUserChirpsPageParams) ([]database.GetUserChirpsPageRow, error)
+	GetUserChirpsSummary(ctx context.Context, userID string) (database.GetUserChirpsSummaryRow, error)
+	GetHashtagChirpsPage(ctx context.Context, arg database.GetHashtagChirpsPageParams) ([]database.GetHashtagChirpsPageRow, error)
+	GetHashtagChirpsSummary(ctx context.Context, hashtag string) (database.GetHashtagChirpsSummaryRow, error)
+	UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error)
+	DeleteChirpById(ctx context.Context, id string) error
+}
+
+type RefreshTokens interface {
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error)
+	GetToken(ctx context.Context, token string) (database.RefreshToken, error)
+	GetAllUserTokens(ctx context.Context, userID string) ([]database.RefreshToken, error)
+	RevokeToken(ctx context.Context, arg database.RevokeTokenParams) error
+	RevokeAllUserTokens(ctx context.Context, arg database.RevokeAllUserTokensParams) error
+}
+
+// Store behaves like the sqlc queries of the same names: lookups that find
+// nothing return sql.ErrNoRows, users pending deletion are hidden from chirp
+// listings, and deleting a user deletes their chirps and refresh tokens.
+type Store interface {
+	Users
+	Chirps
+	RefreshTokens
+}
+
+var _ Store = (*database.Queries)(nil)
+
+// ErrUniqueViolation is returned by backends other than PostgreSQL when a
+// write would duplicate a unique value such as a user's email or handle.
+var ErrUniqueViolation = errors.New("unique constraint violated")
+
+// IsUniqueViolation reports whether err came from a write that would
+// duplicate a unique value, in any backend.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return errors.Is(err, ErrUniqueViolation)
+}
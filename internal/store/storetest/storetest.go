@@ -0,0 +1,356 @@
+// Package storetest checks that a store.Store backend behaves like the
+// others, so the server can run on any of them.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gaba-bouliva/Chirpy/internal/database"
+	"github.com/gaba-bouliva/Chirpy/internal/store"
+)
+
+// Run runs the conformance suite. newStore must return an empty store each
+// time it is called.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s store.Store)
+	}{
+		{"UniqueUsers", testUniqueUsers},
+		{"NotFound", testNotFound},
+		{"EmailVerification", testEmailVerification},
+		{"UserDeletion", testUserDeletion},
+		{"ChirpOrder", testChirpOrder},
+		{"HashtagChirps", testHashtagChirps},
+		{"RefreshTokens", testRefreshTokens},
+		{"ConcurrentChirps", testConcurrentChirps},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// now is truncated to microseconds, the precision PostgreSQL keeps.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func createUser(t *testing.T, s store.Store, id, handle string) database.User {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		ID:             id,
+		CreatedAt:      now(),
+		UpdatedAt:      now(),
+		Email:          handle + "@example.com",
+		HashedPassword: "hash",
+		Handle:         handle,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func createChirp(t *testing.T, s store.Store, id, userID, body string, createdAt time.Time) {
+	t.Helper()
+	_, err := s.CreateChirp(context.Background(), database.CreateChirpParams{
+		ID:        id,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		Body:      body,
+		UserID:    userID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func chirpIDs(rows []database.GetAllChirpsRow) []string {
+	ids := []string{}
+	for _, row := range rows {
+		ids = append(ids, row.Chirp.ID)
+	}
+	return ids
+}
+
+func testUniqueUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createUser(t, s, "1", "ada")
+
+	_, err := s.CreateUser(ctx, database.CreateUserParams{ID: "2", CreatedAt: now(), UpdatedAt: now(), Email: "ada@example.com", Handle: "grace"})
+	if !store.IsUniqueViolation(err) {
+		t.Fatalf("expected a unique violation for a taken email, got %v", err)
+	}
+
+	createUser(t, s, "2", "grace")
+	_, err = s.UpdateUserProfile(ctx, database.UpdateUserProfileParams{ID: "2", Handle: "ada", UpdatedAt: now()})
+	if !store.IsUniqueViolation(err) {
+		t.Fatalf("expected a unique violation for a taken handle, got %v", err)
+	}
+
+	user, err := s.GetUserByHandle(ctx, "grace")
+	if err != nil || user.ID != "2" {
+		t.Fatalf("expected the failed update to leave grace alone, got %+v (%v)", user, err)
+	}
+}
+
+func testNotFound(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if _, err := s.GetUserById(ctx, "missing"); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := s.GetUserByEmail(ctx, "missing@example.com"); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := s.GetChirpById(ctx, "missing"); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := s.GetToken(ctx, "missing"); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+	if _, err := s.ConfirmUserEmail(ctx, database.ConfirmUserEmailParams{ID: "missing", UpdatedAt: now()}); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func testEmailVerification(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "1", "ada")
+
+	if _, err := s.ConfirmUserEmail(ctx, database.ConfirmUserEmailParams{ID: user.ID, UpdatedAt: now()}); err != sql.ErrNoRows {
+		t.Fatalf("expected nothing to confirm without a pending email, got %v", err)
+	}
+
+	token := sql.NullString{String: "verify", Valid: true}
+	_, err := s.SetUserPendingEmail(ctx, database.SetUserPendingEmailParams{
+		PendingEmail:               sql.NullString{String: "lovelace@example.com", Valid: true},
+		EmailVerificationToken:     token,
+		EmailVerificationExpiresAt: sql.NullTime{Time: now().Add(time.Hour), Valid: true},
+		UpdatedAt:                  now(),
+		ID:                         user.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.GetUserByEmailVerificationToken(ctx, token)
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("expected to find the user by token, got %+v (%v)", found, err)
+	}
+
+	confirmed, err := s.ConfirmUserEmail(ctx, database.ConfirmUserEmailParams{ID: user.ID, UpdatedAt: now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Email != "lovelace@example.com" || confirmed.PendingEmail.Valid || confirmed.EmailVerificationToken.Valid {
+		t.Fatalf("unexpected user after confirming %+v", confirmed)
+	}
+}
+
+func testUserDeletion(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "1", "ada")
+	other := createUser(t, s, "2", "grace")
+	start := now()
+
+	createChirp(t, s, "c1", user.ID, "hello #go", start)
+	createChirp(t, s, "c2", other.ID, "hi", start.Add(time.Second))
+	_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "t1", CreatedAt: start, UpdatedAt: start, UserID: user.ID, ExpiresAt: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheduled, err := s.ScheduleUserDeletion(ctx, database.ScheduleUserDeletionParams{
+		DeleteAfter: sql.NullTime{Time: start.Add(time.Hour), Valid: true},
+		UpdatedAt:   start,
+		ID:          user.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scheduled.DeleteAfter.Time.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected delete_after %v, got %v", start.Add(time.Hour), scheduled.DeleteAfter.Time)
+	}
+
+	chirps, err := s.GetAllChirps(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := chirpIDs(chirps); len(ids) != 1 || ids[0] != "c2" {
+		t.Fatalf("expected chirps of users pending deletion to be hidden, got %v", ids)
+	}
+	if _, err := s.GetChirpById(ctx, "c1"); err != sql.ErrNoRows {
+		t.Fatalf("expected a chirp of a user pending deletion to be hidden, got %v", err)
+	}
+
+	deleted, err := s.DeleteUsersDueForDeletion(ctx, sql.NullTime{Time: start.Add(time.Minute), Valid: true})
+	if err != nil || deleted != 0 {
+		t.Fatalf("expected no users deleted before the grace period ends, got %d (%v)", deleted, err)
+	}
+	deleted, err = s.DeleteUsersDueForDeletion(ctx, sql.NullTime{Time: start.Add(time.Hour), Valid: true})
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected 1 user deleted, got %d (%v)", deleted, err)
+	}
+	if _, err := s.GetToken(ctx, "t1"); err != sql.ErrNoRows {
+		t.Fatalf("expected the user's refresh tokens to be deleted, got %v", err)
+	}
+	if _, err := s.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{ID: "c1", UpdatedAt: now()}); err != sql.ErrNoRows {
+		t.Fatalf("expected the user's chirps to be deleted, got %v", err)
+	}
+	if _, err := s.GetUserById(ctx, other.ID); err != nil {
+		t.Fatalf("expected other users to be kept, got %v", err)
+	}
+}
+
+func testChirpOrder(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "1", "ada")
+	start := now()
+
+	createChirp(t, s, "late", user.ID, "third", start.Add(2*time.Hour))
+	createChirp(t, s, "early", user.ID, "first", start)
+	createChirp(t, s, "middle", user.ID, "second", start.Add(time.Hour))
+
+	rows, err := s.GetAllUserChirps(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, row := range rows {
+		ids = append(ids, row.Chirp.ID)
+	}
+	if fmt.Sprint(ids) != "[early middle late]" {
+		t.Fatalf("expected chirps oldest first, got %v", ids)
+	}
+
+	page, err := s.GetUserChirpsPage(ctx, database.GetUserChirpsPageParams{UserID: user.ID, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = nil
+	for _, row := range page {
+		ids = append(ids, row.Chirp.ID)
+	}
+	if fmt.Sprint(ids) != "[middle early]" {
+		t.Fatalf("expected a page of chirps newest first, got %v", ids)
+	}
+
+	summary, err := s.GetUserChirpsSummary(ctx, user.ID)
+	if err != nil || summary.ChirpCount != 3 || !summary.LastUpdated.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("unexpected summary %+v (%v)", summary, err)
+	}
+
+	chirp, err := s.GetChirpById(ctx, "early")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chirp.Chirp.CreatedAt.Equal(start) || chirp.Handle != "ada" {
+		t.Fatalf("unexpected chirp %+v", chirp)
+	}
+}
+
+func testHashtagChirps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "1", "ada")
+	start := now()
+
+	for i, body := range []string{"learning #Go today", "#golang is not #go", "no tags", "#go"} {
+		createChirp(t, s, fmt.Sprint(i), user.ID, body, start.Add(time.Duration(i)*time.Second))
+	}
+
+	rows, err := s.GetHashtagChirpsPage(ctx, database.GetHashtagChirpsPageParams{Hashtag: "go", Limit: 2, Offset: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, row := range rows {
+		ids = append(ids, row.Chirp.ID)
+	}
+	rows, err = s.GetHashtagChirpsPage(ctx, database.GetHashtagChirpsPageParams{Hashtag: "go", Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		ids = append(ids, row.Chirp.ID)
+	}
+	if fmt.Sprint(ids) != "[3 1 0]" {
+		t.Fatalf("unexpected chirps %v", ids)
+	}
+
+	summary, err := s.GetHashtagChirpsSummary(ctx, "go")
+	if err != nil || summary.ChirpCount != 3 || !summary.LastUpdated.Equal(start.Add(3*time.Second)) {
+		t.Fatalf("unexpected summary %+v (%v)", summary, err)
+	}
+	summary, err = s.GetHashtagChirpsSummary(ctx, "rust")
+	if err != nil || summary.ChirpCount != 0 || !summary.LastUpdated.Equal(time.Unix(0, 0)) {
+		t.Fatalf("expected an empty summary, got %+v (%v)", summary, err)
+	}
+}
+
+func testRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "1", "ada")
+	start := now()
+
+	for i, token := range []string{"t1", "t2"} {
+		created := start.Add(time.Duration(i) * time.Second)
+		_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: token, CreatedAt: created, UpdatedAt: created, UserID: user.ID, ExpiresAt: start.Add(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := s.RevokeToken(ctx, database.RevokeTokenParams{RevokedAt: sql.NullTime{Time: start, Valid: true}, UpdatedAt: start, Token: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.GetToken(ctx, "t1")
+	if err != nil || !token.RevokedAt.Valid || !token.ExpiresAt.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected t1 to be revoked, got %+v (%v)", token, err)
+	}
+
+	err = s.RevokeAllUserTokens(ctx, database.RevokeAllUserTokensParams{RevokedAt: sql.NullTime{Time: start.Add(time.Minute), Valid: true}, UpdatedAt: start, UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := s.GetAllUserTokens(ctx, user.ID)
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("expected 2 tokens, got %d (%v)", len(tokens), err)
+	}
+	if !tokens[0].RevokedAt.Time.Equal(start) || !tokens[1].RevokedAt.Time.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected only unrevoked tokens to be revoked again, got %+v", tokens)
+	}
+}
+
+func testConcurrentChirps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	user := createUser(t, s, "1", "ada")
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.CreateChirp(ctx, database.CreateChirpParams{ID: fmt.Sprint(i), CreatedAt: now(), UpdatedAt: now(), UserID: user.ID})
+			if err != nil {
+				t.Error(err)
+			}
+			s.GetAllUserChirps(ctx, user.ID)
+		}()
+	}
+	wg.Wait()
+
+	chirps, _ := s.GetAllUserChirps(ctx, user.ID)
+	if len(chirps) != 50 {
+		t.Fatalf("expected 50 chirps, got %d", len(chirps))
+	}
+}
@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserFollowed = "user.followed"
+	EventMention      = "mention"
+
+	SignatureHeader = "X-Chirpy-Signature"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+
+	// MaxAttempts is how many times a delivery is tried before it is
+	// marked failed.
+	MaxAttempts = 8
+)
+
+var Events = []string{EventChirpCreated, EventChirpDeleted, EventUserFollowed, EventMention}
+
+func IsEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns "t=<unix>,v1=<hex>" where the HMAC-SHA256 covers
+// "<unix>.<body>", so receivers can reject stale or tampered payloads.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Backoff is the delay before retrying after the given number of failed
+// attempts: 30s doubling each time, capped at six hours.
+func Backoff(attempts int) time.Duration {
+	delay := time.Second * 30
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= time.Hour*6 {
+			return time.Hour * 6
+		}
+	}
+	return delay
+}
+
+// Deliver POSTs a signed payload to url. A non-2xx response is returned as
+// an error together with its status code.
+func Deliver(ctx context.Context, client *http.Client, url, secret, event, deliveryId string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryId)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), payload))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
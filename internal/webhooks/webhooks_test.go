@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != time.Second*30 {
+		t.Fatalf("expected first retry after 30s, got %v", Backoff(1))
+	}
+	if Backoff(3) != time.Minute*2 {
+		t.Fatalf("expected third retry after 2m, got %v", Backoff(3))
+	}
+	if Backoff(50) != time.Hour*6 {
+		t.Fatalf("expected backoff to be capped at 6h, got %v", Backoff(50))
+	}
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	payload := []byte(`{"type":"chirp.created"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(EventHeader) != EventChirpCreated || r.Header.Get(DeliveryHeader) != "d1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		header := r.Header.Get(SignatureHeader)
+		unix, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(header, ",")[0], "t="), 10, 64)
+		if err != nil || header != Sign("secret", time.Unix(unix, 0), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	status, err := Deliver(context.Background(), server.Client(), server.URL, "secret", EventChirpCreated, "d1", payload)
+	if err != nil {
+		t.Fatalf("expected no error, got %v (status %d)", err, status)
+	}
+}
+
+func TestDeliverReportsFailureStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	status, err := Deliver(context.Background(), server.Client(), server.URL, "secret", EventChirpCreated, "d1", []byte(`{}`))
+	if err == nil {
+		t.Fatalf("expected error for 503 response")
+	}
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, status)
+	}
+}
@@ -0,0 +1,124 @@
+-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, public_key_pem, private_key_pem, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetActorKey :one
+SELECT * FROM actor_keys WHERE user_id = $1 LIMIT 1;
+
+-- name: UpsertRemoteActor :one
+INSERT INTO remote_actors (id, preferred_username, name, url, inbox, shared_inbox, public_key_id, public_key_pem, fetched_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (id) DO UPDATE
+SET preferred_username = EXCLUDED.preferred_username,
+    name = EXCLUDED.name,
+    url = EXCLUDED.url,
+    inbox = EXCLUDED.inbox,
+    shared_inbox = EXCLUDED.shared_inbox,
+    public_key_id = EXCLUDED.public_key_id,
+    public_key_pem = EXCLUDED.public_key_pem,
+    fetched_at = EXCLUDED.fetched_at
+RETURNING *;
+
+-- name: GetRemoteActor :one
+SELECT * FROM remote_actors WHERE id = $1 LIMIT 1;
+
+-- name: DeleteRemoteActor :exec
+DELETE FROM remote_actors WHERE id = $1;
+
+-- name: CreateRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_id, activity_id, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET activity_id = EXCLUDED.activity_id;
+
+-- name: DeleteRemoteFollower :exec
+DELETE FROM remote_followers WHERE user_id = $1 AND actor_id = $2;
+
+-- name: CountRemoteFollowers :one
+SELECT count(*) FROM remote_followers WHERE user_id = $1;
+
+-- name: GetRemoteFollowerInboxes :many
+SELECT DISTINCT COALESCE(NULLIF(remote_actors.shared_inbox, ''), remote_actors.inbox)::text AS inbox
+FROM remote_followers
+JOIN remote_actors ON remote_actors.id = remote_followers.actor_id
+WHERE remote_followers.user_id = $1;
+
+-- name: CreateRemoteFollowing :exec
+INSERT INTO remote_following (user_id, actor_id, activity_id, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET activity_id = EXCLUDED.activity_id;
+
+-- name: AcceptRemoteFollowing :execrows
+UPDATE remote_following SET accepted = true
+WHERE activity_id = $1 AND actor_id = $2;
+
+-- name: GetRemoteFollowing :one
+SELECT * FROM remote_following WHERE user_id = $1 AND actor_id = $2 LIMIT 1;
+
+-- name: DeleteRemoteFollowing :exec
+DELETE FROM remote_following WHERE user_id = $1 AND actor_id = $2;
+
+-- name: IsRemoteActorFollowed :one
+SELECT EXISTS (
+    SELECT 1 FROM remote_following WHERE actor_id = $1 AND accepted
+)::boolean AS followed;
+
+-- name: CreateRemoteNote :exec
+INSERT INTO remote_notes (id, actor_id, content, url, published, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO UPDATE SET content = EXCLUDED.content, url = EXCLUDED.url
+WHERE remote_notes.actor_id = EXCLUDED.actor_id;
+
+-- name: DeleteRemoteNote :exec
+DELETE FROM remote_notes WHERE id = $1 AND actor_id = $2;
+
+-- name: GetRemoteTimeline :many
+SELECT sqlc.embed(remote_notes), remote_actors.preferred_username, remote_actors.name, remote_actors.url AS actor_url
+FROM remote_notes
+JOIN remote_actors ON remote_actors.id = remote_notes.actor_id
+JOIN remote_following ON remote_following.actor_id = remote_notes.actor_id
+WHERE remote_following.user_id = sqlc.arg(user_id) AND remote_following.accepted
+ORDER BY remote_notes.published DESC
+LIMIT sqlc.arg(max_notes);
+
+-- name: CreateRemoteLike :one
+INSERT INTO remote_likes (chirp_id, actor_id, activity_id, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (chirp_id, actor_id) DO UPDATE SET activity_id = EXCLUDED.activity_id
+RETURNING (xmax = 0)::boolean AS inserted;
+
+-- name: DeleteRemoteLike :many
+DELETE FROM remote_likes WHERE activity_id = $1 AND actor_id = $2
+RETURNING chirp_id;
+
+-- name: CreateFederationDelivery :exec
+INSERT INTO federation_deliveries (id, user_id, inbox, activity, status, next_attempt_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ClaimDueFederationDeliveries :many
+UPDATE federation_deliveries SET next_attempt_at = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT id FROM federation_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)::timestamp
+    ORDER BY next_attempt_at ASC
+    LIMIT sqlc.arg(max_deliveries)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateFederationDeliveryResult :exec
+UPDATE federation_deliveries SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4
+WHERE id = $5;
+
+-- name: GetUserRemoteFollowers :many
+SELECT * FROM remote_followers WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetUserRemoteFollowing :many
+SELECT * FROM remote_following WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetUserRemoteLikes :many
+-- Likes of the user's chirps.
+SELECT remote_likes.* FROM remote_likes
+JOIN chirps ON chirps.id = remote_likes.chirp_id
+WHERE chirps.user_id = $1
+ORDER BY remote_likes.created_at;
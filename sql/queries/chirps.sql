@@ -4,13 +4,56 @@ VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
 -- name: GetChirpById :one
-SELECT * FROM chirps WHERE id = $1 LIMIT 1;
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.delete_after IS NULL
+LIMIT 1;
 
 -- name: GetAllChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.delete_after IS NULL
+ORDER BY chirps.created_at ASC;
 
 -- name: GetAllUserChirps :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.delete_after IS NULL
+ORDER BY chirps.created_at ASC;
 
 -- name: DeleteChirpById :exec
 DELETE FROM chirps WHERE id = $1;
+
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $1, updated_at = $2 WHERE id = $3
+RETURNING *;
+
+-- name: GetUserChirpsPage :many
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = sqlc.arg(user_id) AND users.delete_after IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: GetUserChirpsSummary :one
+SELECT count(*) AS chirp_count, COALESCE(max(chirps.updated_at), 'epoch')::timestamp AS last_updated FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.delete_after IS NULL;
+
+-- name: GetHashtagChirpsPage :many
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags(chirps.body) @> ARRAY[sqlc.arg(hashtag)::text] AND users.delete_after IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: GetHashtagChirpsSummary :one
+SELECT count(*) AS chirp_count, COALESCE(max(chirps.updated_at), 'epoch')::timestamp AS last_updated FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags(chirps.body) @> ARRAY[sqlc.arg(hashtag)::text] AND users.delete_after IS NULL;
+
+-- name: GetVisibleChirpIds :many
+SELECT chirps.id FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = ANY(sqlc.arg(ids)::text[]) AND users.delete_after IS NULL;
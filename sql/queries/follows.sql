@@ -0,0 +1,24 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollow :exec
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetUserProfileStats :one
+SELECT
+    (SELECT count(*) FROM chirps WHERE chirps.user_id = $1) AS chirp_count,
+    (SELECT count(*) FROM follows WHERE follows.followee_id = $1) AS follower_count,
+    (SELECT count(*) FROM follows WHERE follows.follower_id = $1) AS following_count;
+
+-- name: GetFolloweeIds :many
+SELECT followee_id FROM follows WHERE follower_id = $1;
+
+-- name: IsFollowing :one
+SELECT EXISTS (
+    SELECT 1 FROM follows WHERE follower_id = $1 AND followee_id = $2
+) AS following;
+
+-- name: GetUserFollows :many
+SELECT * FROM follows WHERE follower_id = $1 OR followee_id = $1 ORDER BY created_at;
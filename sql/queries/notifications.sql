@@ -0,0 +1,59 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, user_id, type, actor_id, remote_actor_id, chirp_id, group_key, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetNotificationGroups :many
+SELECT
+    n.group_key,
+    n.type,
+    n.chirp_id,
+    bool_and(n.read_at IS NOT NULL)::boolean AS read,
+    count(DISTINCT COALESCE(n.actor_id, n.remote_actor_id)) AS actor_count,
+    count(*) AS notification_count,
+    max(n.created_at)::timestamp AS latest_at,
+    (array_agg(n.id ORDER BY n.created_at DESC))[1:sqlc.arg(max_ids)::int]::text[] AS ids,
+    (array_agg(
+        COALESCE(u.handle, ra.preferred_username || '@' || split_part(ra.id, '/', 3))
+        ORDER BY n.created_at DESC
+    ))[1:sqlc.arg(max_actors)::int]::text[] AS actor_handles
+FROM notifications n
+LEFT JOIN users u ON u.id = n.actor_id
+LEFT JOIN remote_actors ra ON ra.id = n.remote_actor_id
+WHERE n.user_id = sqlc.arg(user_id)
+GROUP BY n.group_key, n.type, n.chirp_id
+ORDER BY latest_at DESC
+LIMIT sqlc.arg(max_groups);
+
+-- name: GetUnreadNotificationCount :one
+SELECT count(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = sqlc.arg(read_at)
+WHERE user_id = sqlc.arg(user_id) AND read_at IS NULL AND id = ANY(sqlc.arg(ids)::text[]);
+
+-- name: MarkNotificationGroupsRead :execrows
+UPDATE notifications SET read_at = sqlc.arg(read_at)
+WHERE user_id = sqlc.arg(user_id) AND read_at IS NULL AND group_key = ANY(sqlc.arg(group_keys)::text[]);
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = $1
+WHERE user_id = $2 AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: IsNotificationEnabled :one
+SELECT COALESCE(
+    (SELECT enabled FROM notification_preferences WHERE user_id = $1 AND type = $2),
+    true
+)::boolean AS enabled;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at;
+
+-- name: GetUserNotifications :many
+SELECT * FROM notifications WHERE user_id = $1 ORDER BY created_at;
@@ -0,0 +1,60 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, user_id, url, secret, events, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1 LIMIT 1;
+
+-- name: GetUserWebhookEndpoints :many
+SELECT * FROM webhook_endpoints WHERE user_id = $1 ORDER BY created_at ASC;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhookEndpointsForEvent :many
+SELECT * FROM webhook_endpoints
+WHERE sqlc.arg(event)::text = ANY(events)
+AND (sqlc.narg(user_id)::varchar IS NULL OR user_id = sqlc.narg(user_id)::varchar);
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event, payload, status, next_attempt_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = $1 LIMIT 1;
+
+-- name: GetEndpointWebhookDeliveries :many
+SELECT * FROM webhook_deliveries WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)::timestamp
+    ORDER BY next_attempt_at ASC
+    LIMIT sqlc.arg(max_deliveries)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateWebhookDeliveryResult :exec
+UPDATE webhook_deliveries SET status = $1, attempts = $2, next_attempt_at = $3, last_attempted_at = $4
+WHERE id = $5;
+
+-- name: ResetWebhookDelivery :exec
+UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $1
+WHERE id = $2;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, status_code, error, duration_ms, attempted_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY attempted_at ASC;
+
+-- name: GetUserWebhookDeliveries :many
+SELECT webhook_deliveries.* FROM webhook_deliveries
+JOIN webhook_endpoints ON webhook_endpoints.id = webhook_deliveries.endpoint_id
+WHERE webhook_endpoints.user_id = $1
+ORDER BY webhook_deliveries.created_at;
@@ -3,8 +3,8 @@ INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at,
 VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
--- name: GetTokenByUserId :one
-SELECT * FROM refresh_tokens WHERE user_id = $1 LIMIT 1;
+-- name: GetAllUserTokens :many
+SELECT * FROM refresh_tokens WHERE user_id = $1 ORDER BY created_at ASC;
 
 -- name: GetToken :one
 SELECT * FROM refresh_tokens WHERE token = $1 LIMIT 1;
@@ -12,9 +12,5 @@ SELECT * FROM refresh_tokens WHERE token = $1 LIMIT 1;
 -- name: RevokeToken :exec
 UPDATE refresh_tokens SET revoked_at = $1, updated_at = $2 WHERE token = $3;
 
--- name: DeleteUserToken :exec
-DELETE FROM refresh_tokens WHERE user_id = $1;
-
-
--- name: DeleteAllTokens :exec
-DELETE FROM refresh_tokens;
\ No newline at end of file
+-- name: RevokeAllUserTokens :exec
+UPDATE refresh_tokens SET revoked_at = $1, updated_at = $2 WHERE user_id = $3 AND revoked_at IS NULL;
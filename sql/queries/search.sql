@@ -0,0 +1,79 @@
+-- name: SearchUsers :many
+SELECT id, handle, display_name, bio, avatar_url FROM users
+WHERE delete_after IS NULL
+AND (handle LIKE sqlc.arg(prefix)::text || '%' OR lower(display_name) LIKE sqlc.arg(prefix)::text || '%')
+ORDER BY (handle = sqlc.arg(handle)::text) DESC, length(handle) ASC, handle ASC
+LIMIT sqlc.arg(max_results);
+
+-- name: GetStaleSuggestionUserIds :many
+-- The users whose suggestions were recomputed longest ago, those never
+-- computed first.
+SELECT users.id FROM users
+LEFT JOIN user_suggestion_runs ON user_suggestion_runs.user_id = users.id
+WHERE users.delete_after IS NULL
+ORDER BY user_suggestion_runs.computed_at ASC NULLS FIRST, users.id ASC
+LIMIT $1;
+
+-- name: DeleteUserSuggestions :exec
+DELETE FROM user_suggestions WHERE user_id = ANY(sqlc.arg(user_ids)::text[]);
+
+-- name: RefreshUserSuggestions :exec
+-- Only the given users' suggestions are computed, so each run's work is
+-- bounded by the batch rather than by every pair of users.
+WITH friends_of_friends AS (
+    SELECT f1.follower_id AS user_id, f2.followee_id AS suggested_user_id, count(*) * 2.0 AS score
+    FROM follows f1
+    JOIN follows f2 ON f2.follower_id = f1.followee_id
+    WHERE f1.follower_id = ANY(sqlc.arg(user_ids)::text[])
+    AND f2.followee_id <> f1.follower_id
+    GROUP BY f1.follower_id, f2.followee_id
+), batch_hashtags AS (
+    SELECT DISTINCT chirps.user_id, tag[1] AS hashtag
+    FROM chirps, regexp_matches(lower(chirps.body), '#([a-z0-9_]+)', 'g') AS tag
+    WHERE chirps.user_id = ANY(sqlc.arg(user_ids)::text[])
+), user_hashtags AS (
+    SELECT DISTINCT chirps.user_id, tag[1] AS hashtag
+    FROM chirps, regexp_matches(lower(chirps.body), '#([a-z0-9_]+)', 'g') AS tag
+    WHERE tag[1] IN (SELECT hashtag FROM batch_hashtags)
+), shared_hashtags AS (
+    SELECT h1.user_id, h2.user_id AS suggested_user_id, count(*) * 1.0 AS score
+    FROM batch_hashtags h1
+    JOIN user_hashtags h2 ON h2.hashtag = h1.hashtag AND h2.user_id <> h1.user_id
+    GROUP BY h1.user_id, h2.user_id
+), candidates AS (
+    SELECT c.user_id, c.suggested_user_id, sum(c.score) AS score
+    FROM (
+        SELECT * FROM friends_of_friends
+        UNION ALL
+        SELECT * FROM shared_hashtags
+    ) c
+    GROUP BY c.user_id, c.suggested_user_id
+)
+INSERT INTO user_suggestions (user_id, suggested_user_id, score, computed_at)
+SELECT candidates.user_id, candidates.suggested_user_id, candidates.score, sqlc.arg(computed_at)
+FROM candidates
+JOIN users ON users.id = candidates.suggested_user_id
+WHERE users.delete_after IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = candidates.user_id AND follows.followee_id = candidates.suggested_user_id
+);
+
+-- name: MarkUserSuggestionsComputed :exec
+INSERT INTO user_suggestion_runs (user_id, computed_at)
+SELECT users.id, sqlc.arg(computed_at)::timestamp FROM users
+WHERE users.id = ANY(sqlc.arg(user_ids)::text[])
+ON CONFLICT (user_id) DO UPDATE SET computed_at = EXCLUDED.computed_at;
+
+-- name: GetUserSuggestions :many
+SELECT users.id, users.handle, users.display_name, users.bio, users.avatar_url, user_suggestions.score
+FROM user_suggestions
+JOIN users ON users.id = user_suggestions.suggested_user_id
+WHERE user_suggestions.user_id = $1
+AND users.delete_after IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM follows
+    WHERE follows.follower_id = user_suggestions.user_id AND follows.followee_id = user_suggestions.suggested_user_id
+)
+ORDER BY user_suggestions.score DESC, users.handle ASC
+LIMIT $2;
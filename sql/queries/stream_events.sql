@@ -0,0 +1,23 @@
+-- name: LockStreamEvents :exec
+-- Held until the transaction ends, so events are committed in id order.
+SELECT pg_advisory_xact_lock(hashtext('chirpy_stream'));
+
+-- name: CreateStreamEvent :one
+INSERT INTO stream_events (event, author_id, recipient_id, hashtags, payload, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: NotifyStreamEvent :exec
+SELECT pg_notify('chirpy_stream', sqlc.arg(id)::text);
+
+-- name: GetStreamEventsAfter :many
+SELECT * FROM stream_events
+WHERE id > sqlc.arg(after_id)
+ORDER BY id ASC
+LIMIT sqlc.arg(max_events);
+
+-- name: GetLastStreamEventId :one
+SELECT COALESCE(max(id), 0)::bigint AS id FROM stream_events;
+
+-- name: DeleteStreamEventsBefore :exec
+DELETE FROM stream_events WHERE created_at < $1;
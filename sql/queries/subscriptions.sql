@@ -0,0 +1,43 @@
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_start, current_period_end, cancel_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetLatestUserSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1;
+
+-- name: GetUserSubscriptions :many
+SELECT * FROM subscriptions WHERE user_id = $1 ORDER BY created_at DESC;
+
+-- name: UpdateSubscription :one
+UPDATE subscriptions SET plan = $1, status = $2, current_period_start = $3, current_period_end = $4, cancel_at = $5, updated_at = $6
+WHERE id = $7
+RETURNING *;
+
+-- name: ExpireSubscriptions :execrows
+UPDATE subscriptions SET status = 'expired', updated_at = sqlc.arg(now)::timestamp
+WHERE status IN ('active', 'past_due')
+AND (current_period_end <= sqlc.arg(now)::timestamp OR (cancel_at IS NOT NULL AND cancel_at <= sqlc.arg(now)::timestamp));
+
+-- name: SyncUserChirpyRed :exec
+UPDATE users SET is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND subscriptions.status IN ('active', 'past_due')
+    AND subscriptions.current_period_end > sqlc.arg(now)::timestamp
+    AND (subscriptions.cancel_at IS NULL OR subscriptions.cancel_at > sqlc.arg(now)::timestamp)
+)
+WHERE users.id = sqlc.arg(user_id);
+
+-- name: SyncAllUsersChirpyRed :execrows
+UPDATE users SET is_chirpy_red = NOT is_chirpy_red
+WHERE is_chirpy_red <> EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND subscriptions.status IN ('active', 'past_due')
+    AND subscriptions.current_period_end > sqlc.arg(now)::timestamp
+    AND (subscriptions.cancel_at IS NULL OR subscriptions.cancel_at > sqlc.arg(now)::timestamp)
+);
+
+-- name: LockUserForBilling :one
+SELECT id FROM users WHERE id = $1 FOR UPDATE;
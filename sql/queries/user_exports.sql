@@ -0,0 +1,21 @@
+-- name: CreateUserExport :one
+INSERT INTO user_exports (id, user_id, status, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetUserExport :one
+SELECT * FROM user_exports WHERE id = $1 LIMIT 1;
+
+-- name: UpdateUserExportStatus :exec
+UPDATE user_exports SET status = $1, blob_key = $2, updated_at = $3 WHERE id = $4;
+
+-- name: GetExpiredUserExports :many
+SELECT * FROM user_exports WHERE updated_at < $1 ORDER BY updated_at LIMIT $2;
+
+-- name: GetUserExportsDueForDeletion :many
+SELECT user_exports.* FROM user_exports
+JOIN users ON users.id = user_exports.user_id
+WHERE users.delete_after IS NOT NULL AND users.delete_after <= $1;
+
+-- name: DeleteUserExport :exec
+DELETE FROM user_exports WHERE id = $1;
@@ -1,6 +1,6 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle, display_name)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
 -- name: GetUserById :one
@@ -9,14 +9,41 @@ SELECT * FROM users WHERE id = $1 LIMIT 1;
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1 LIMIT 1;
 
--- name: UpdateUser :one
-UPDATE users set email = $1, hashed_password = $2, updated_at = $3 
-WHERE id = $4
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE handle = $1 LIMIT 1;
+
+-- name: UpdateUserPassword :one
+UPDATE users set hashed_password = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: SetUserPendingEmail :one
+UPDATE users set pending_email = $1, email_verification_token = $2, email_verification_expires_at = $3, updated_at = $4
+WHERE id = $5
+RETURNING *;
+
+-- name: GetUserByEmailVerificationToken :one
+SELECT * FROM users WHERE email_verification_token = $1 LIMIT 1;
+
+-- name: ConfirmUserEmail :one
+UPDATE users set email = pending_email, pending_email = NULL, email_verification_token = NULL, email_verification_expires_at = NULL, updated_at = $1
+WHERE id = $2 AND pending_email IS NOT NULL
+RETURNING *;
+
+-- name: UpdateUserProfile :one
+UPDATE users set handle = $1, display_name = $2, bio = $3, avatar_url = $4, website = $5, updated_at = $6
+WHERE id = $7
 RETURNING *;
 
--- name: UpdateUserSetChirpyRed :exec
+-- name: UpdateUserSetChirpyRed :execrows
 UPDATE users set is_chirpy_red = $1 WHERE id = $2;
 
+-- name: ScheduleUserDeletion :one
+UPDATE users set delete_after = $1, updated_at = $2 WHERE id = $3
+RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users set delete_after = NULL, updated_at = $1 WHERE id = $2;
 
--- name: DeleteAllUsers :exec
-DELETE FROM users;
\ No newline at end of file
+-- name: DeleteUsersDueForDeletion :execrows
+DELETE FROM users WHERE delete_after IS NOT NULL AND delete_after <= $1;
@@ -0,0 +1,26 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, provider, event, payload, status, received_at, claimed_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events WHERE id = $1 LIMIT 1;
+
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events SET status = 'processing', claimed_at = sqlc.arg(now)::timestamp
+WHERE id = sqlc.arg(id)
+AND (
+    status IN ('received', 'failed')
+    OR (status = 'processing' AND claimed_at < sqlc.arg(stale_before)::timestamp)
+)
+RETURNING *;
+
+-- name: UpdateWebhookEventStatus :exec
+UPDATE webhook_events SET status = $1, error = $2, processed_at = $3 WHERE id = $4;
+
+-- name: GetUserWebhookEvents :many
+-- Polka events name the user in their payload.
+SELECT * FROM webhook_events
+WHERE provider = 'polka' AND payload::jsonb -> 'data' ->> 'user_id' = sqlc.arg(user_id)::text
+ORDER BY received_at;
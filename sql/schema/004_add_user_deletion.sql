@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN delete_after TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN delete_after;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id VARCHAR(255) NOT NULL,
+    followee_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (follower_id, followee_id),
+    CONSTRAINT no_self_follow CHECK (follower_id <> followee_id),
+    CONSTRAINT fk_follower
+    FOREIGN KEY (follower_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_followee
+    FOREIGN KEY (followee_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX follows_followee_id_idx ON follows (followee_id);
+
+-- +goose Down
+DROP TABLE follows;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN pending_email TEXT DEFAULT NULL;
+ALTER TABLE users ADD COLUMN email_verification_token TEXT DEFAULT NULL UNIQUE;
+ALTER TABLE users ADD COLUMN email_verification_expires_at TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN email_verification_expires_at;
+ALTER TABLE users DROP COLUMN email_verification_token;
+ALTER TABLE users DROP COLUMN pending_email;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_handle_trgm_idx ON users USING gin (handle gin_trgm_ops);
+CREATE INDEX users_display_name_trgm_idx ON users USING gin (lower(display_name) gin_trgm_ops);
+
+CREATE TABLE user_suggestions (
+    user_id VARCHAR(255) NOT NULL,
+    suggested_user_id VARCHAR(255) NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (user_id, suggested_user_id),
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_suggested_user
+    FOREIGN KEY (suggested_user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE user_suggestions;
+DROP INDEX users_display_name_trgm_idx;
+DROP INDEX users_handle_trgm_idx;
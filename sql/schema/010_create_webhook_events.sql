@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    provider TEXT NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT DEFAULT NULL,
+    received_at TIMESTAMP NOT NULL,
+    processed_at TIMESTAMP DEFAULT NULL
+);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    cancel_at TIMESTAMP DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX subscriptions_user_id_idx ON subscriptions (user_id, created_at);
+
+-- existing Chirpy Red members predate billing periods, so their subscription
+-- never runs out on its own; it ends when Polka sends a downgrade
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_start, current_period_end, created_at, updated_at)
+SELECT md5(random()::text || id), id, 'chirpy_red', 'active', NOW(), TIMESTAMP '9999-12-31 00:00:00', NOW(), NOW()
+FROM users WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
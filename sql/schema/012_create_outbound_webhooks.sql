@@ -0,0 +1,52 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE webhook_deliveries (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    endpoint_id VARCHAR(255) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempted_at TIMESTAMP DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_endpoint
+    FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON webhook_deliveries (endpoint_id, created_at);
+
+CREATE TABLE webhook_delivery_attempts (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    delivery_id VARCHAR(255) NOT NULL,
+    status_code INTEGER DEFAULT NULL,
+    error TEXT DEFAULT NULL,
+    duration_ms INTEGER NOT NULL,
+    attempted_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_delivery
+    FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE stream_events (
+    id BIGSERIAL PRIMARY KEY,
+    event TEXT NOT NULL,
+    author_id VARCHAR(255) NOT NULL,
+    hashtags TEXT[] NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX stream_events_created_at_idx ON stream_events (created_at);
+
+-- +goose Down
+DROP TABLE stream_events;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE stream_events
+ADD COLUMN recipient_id VARCHAR(255) DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE stream_events
+DROP COLUMN recipient_id;
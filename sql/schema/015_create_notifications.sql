@@ -0,0 +1,40 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    type TEXT NOT NULL,
+    actor_id VARCHAR(255) NOT NULL,
+    chirp_id VARCHAR(255) DEFAULT NULL,
+    group_key TEXT NOT NULL,
+    read_at TIMESTAMP DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_actor
+    FOREIGN KEY (actor_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_chirp
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+
+CREATE TABLE notification_preferences (
+    user_id VARCHAR(255) NOT NULL,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (user_id, type),
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
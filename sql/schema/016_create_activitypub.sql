@@ -0,0 +1,113 @@
+-- +goose Up
+CREATE TABLE actor_keys (
+    user_id VARCHAR(255) NOT NULL PRIMARY KEY,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE remote_actors (
+    id TEXT NOT NULL PRIMARY KEY,
+    preferred_username TEXT NOT NULL,
+    name TEXT NOT NULL,
+    url TEXT NOT NULL,
+    inbox TEXT NOT NULL,
+    shared_inbox TEXT NOT NULL,
+    public_key_id TEXT NOT NULL,
+    public_key_pem TEXT NOT NULL,
+    fetched_at TIMESTAMP NOT NULL
+);
+
+-- remote actors following local users
+CREATE TABLE remote_followers (
+    user_id VARCHAR(255) NOT NULL,
+    actor_id TEXT NOT NULL,
+    activity_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (user_id, actor_id),
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_actor
+    FOREIGN KEY (actor_id) REFERENCES remote_actors(id)
+    ON DELETE CASCADE
+);
+
+-- local users following remote actors
+CREATE TABLE remote_following (
+    user_id VARCHAR(255) NOT NULL,
+    actor_id TEXT NOT NULL,
+    activity_id TEXT NOT NULL,
+    accepted BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (user_id, actor_id),
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_actor
+    FOREIGN KEY (actor_id) REFERENCES remote_actors(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE remote_notes (
+    id TEXT NOT NULL PRIMARY KEY,
+    actor_id TEXT NOT NULL,
+    content TEXT NOT NULL,
+    url TEXT NOT NULL,
+    published TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_actor
+    FOREIGN KEY (actor_id) REFERENCES remote_actors(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX remote_notes_actor_id_idx ON remote_notes (actor_id, published);
+
+CREATE TABLE remote_likes (
+    chirp_id VARCHAR(255) NOT NULL,
+    actor_id TEXT NOT NULL,
+    activity_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (chirp_id, actor_id),
+    CONSTRAINT fk_chirp
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    CONSTRAINT fk_actor
+    FOREIGN KEY (actor_id) REFERENCES remote_actors(id)
+    ON DELETE CASCADE
+);
+
+CREATE TABLE federation_deliveries (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    inbox TEXT NOT NULL,
+    activity TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX federation_deliveries_pending_idx ON federation_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE federation_deliveries;
+DROP TABLE remote_likes;
+DROP TABLE remote_notes;
+DROP TABLE remote_following;
+DROP TABLE remote_followers;
+DROP TABLE remote_actors;
+DROP TABLE actor_keys;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- chirp_hashtags extracts the distinct lowercased hashtags of a chirp body,
+-- matching the server's own parsing, so they can be indexed
+-- +goose StatementBegin
+CREATE FUNCTION chirp_hashtags(body TEXT) RETURNS TEXT[]
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+    SELECT coalesce(array_agg(DISTINCT match[1]), '{}')
+    FROM regexp_matches(lower(body), '#([a-z0-9_]+)', 'g') AS match
+$$;
+-- +goose StatementEnd
+
+CREATE INDEX chirps_hashtags_idx ON chirps USING gin (chirp_hashtags(body));
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+DROP INDEX chirps_hashtags_idx;
+DROP FUNCTION chirp_hashtags(TEXT);
@@ -0,0 +1,15 @@
+-- +goose Up
+-- notifications caused by remote actors, such as federated likes, point at
+-- the cached actor instead of a local user
+ALTER TABLE notifications ALTER COLUMN actor_id DROP NOT NULL;
+ALTER TABLE notifications
+ADD COLUMN remote_actor_id TEXT DEFAULT NULL
+REFERENCES remote_actors(id) ON DELETE CASCADE;
+ALTER TABLE notifications
+ADD CONSTRAINT notifications_one_actor CHECK ((actor_id IS NULL) <> (remote_actor_id IS NULL));
+
+-- +goose Down
+DELETE FROM notifications WHERE actor_id IS NULL;
+ALTER TABLE notifications DROP CONSTRAINT notifications_one_actor;
+ALTER TABLE notifications DROP COLUMN remote_actor_id;
+ALTER TABLE notifications ALTER COLUMN actor_id SET NOT NULL;
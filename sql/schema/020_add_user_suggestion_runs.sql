@@ -0,0 +1,16 @@
+-- +goose Up
+-- when each user's suggestions were last recomputed, so the suggestions job
+-- can refresh the stalest users in bounded batches
+CREATE TABLE user_suggestion_runs (
+    user_id VARCHAR(255) PRIMARY KEY,
+    computed_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX user_suggestion_runs_computed_at_idx ON user_suggestion_runs (computed_at);
+
+-- +goose Down
+DROP TABLE user_suggestion_runs;
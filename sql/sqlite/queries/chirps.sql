@@ -0,0 +1,68 @@
+-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id )
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetChirpById :one
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = ? AND users.delete_after IS NULL
+LIMIT 1;
+
+-- name: GetAllChirps :many
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.delete_after IS NULL
+ORDER BY chirps.created_at ASC;
+
+-- name: GetAllUserChirps :many
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = ? AND users.delete_after IS NULL
+ORDER BY chirps.created_at ASC;
+
+-- name: DeleteChirpById :exec
+DELETE FROM chirps WHERE id = ?;
+
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = ?, updated_at = ? WHERE id = ?
+RETURNING *;
+
+-- name: GetUserChirpsPage :many
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = sqlc.arg(user_id) AND users.delete_after IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountUserChirps :one
+SELECT count(*) FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = ? AND users.delete_after IS NULL;
+
+-- name: GetUserChirpsLastUpdated :one
+SELECT chirps.updated_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = ? AND users.delete_after IS NULL
+ORDER BY chirps.updated_at DESC
+LIMIT 1;
+
+-- name: GetHashtagChirpsPage :many
+SELECT sqlc.embed(chirps), users.handle, users.display_name FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE lower(chirps.body) REGEXP ('#' || CAST(sqlc.arg(hashtag) AS TEXT) || '([^a-z0-9_]|$)') AND users.delete_after IS NULL
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountHashtagChirps :one
+SELECT count(*) FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE lower(chirps.body) REGEXP ('#' || CAST(sqlc.arg(hashtag) AS TEXT) || '([^a-z0-9_]|$)') AND users.delete_after IS NULL;
+
+-- name: GetHashtagChirpsLastUpdated :one
+SELECT chirps.updated_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE lower(chirps.body) REGEXP ('#' || CAST(sqlc.arg(hashtag) AS TEXT) || '([^a-z0-9_]|$)') AND users.delete_after IS NULL
+ORDER BY chirps.updated_at DESC
+LIMIT 1;
@@ -0,0 +1,16 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at )
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetAllUserTokens :many
+SELECT * FROM refresh_tokens WHERE user_id = ? ORDER BY created_at ASC;
+
+-- name: GetToken :one
+SELECT * FROM refresh_tokens WHERE token = ? LIMIT 1;
+
+-- name: RevokeToken :exec
+UPDATE refresh_tokens SET revoked_at = ?, updated_at = ? WHERE token = ?;
+
+-- name: RevokeAllUserTokens :exec
+UPDATE refresh_tokens SET revoked_at = ?, updated_at = ? WHERE user_id = ? AND revoked_at IS NULL;
@@ -0,0 +1,49 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle, display_name)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetUserById :one
+SELECT * FROM users WHERE id = ? LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users WHERE email = ? LIMIT 1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE handle = ? LIMIT 1;
+
+-- name: UpdateUserPassword :one
+UPDATE users set hashed_password = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: SetUserPendingEmail :one
+UPDATE users set pending_email = ?, email_verification_token = ?, email_verification_expires_at = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: GetUserByEmailVerificationToken :one
+SELECT * FROM users WHERE email_verification_token = ? LIMIT 1;
+
+-- name: ConfirmUserEmail :one
+UPDATE users set email = pending_email, pending_email = NULL, email_verification_token = NULL, email_verification_expires_at = NULL, updated_at = ?
+WHERE id = ? AND pending_email IS NOT NULL
+RETURNING *;
+
+-- name: UpdateUserProfile :one
+UPDATE users set handle = ?, display_name = ?, bio = ?, avatar_url = ?, website = ?, updated_at = ?
+WHERE id = ?
+RETURNING *;
+
+-- name: UpdateUserSetChirpyRed :execrows
+UPDATE users set is_chirpy_red = ? WHERE id = ?;
+
+-- name: ScheduleUserDeletion :one
+UPDATE users set delete_after = ?, updated_at = ? WHERE id = ?
+RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users set delete_after = NULL, updated_at = ? WHERE id = ?;
+
+-- name: DeleteUsersDueForDeletion :execrows
+DELETE FROM users WHERE delete_after IS NOT NULL AND delete_after <= ?;
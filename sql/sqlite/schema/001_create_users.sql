@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE users (
+    id TEXT NOT NULL PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    hashed_password TEXT NOT NULL DEFAULT 'unset',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE,
+    delete_after TIMESTAMP DEFAULT NULL,
+    handle TEXT NOT NULL UNIQUE,
+    display_name TEXT NOT NULL DEFAULT '',
+    bio TEXT NOT NULL DEFAULT '',
+    avatar_url TEXT NOT NULL DEFAULT '',
+    website TEXT NOT NULL DEFAULT '',
+    pending_email TEXT DEFAULT NULL,
+    email_verification_token TEXT DEFAULT NULL UNIQUE,
+    email_verification_expires_at TIMESTAMP DEFAULT NULL
+);
+
+-- +goose Down
+DROP TABLE users;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE chirps (
+    id TEXT NOT NULL PRIMARY KEY,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id TEXT NOT NULL,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirps_user_id_idx ON chirps (user_id);
+
+-- +goose Down
+DROP TABLE chirps;
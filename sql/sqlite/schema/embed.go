@@ -0,0 +1,8 @@
+// Package schema embeds the SQLite migrations so a binary can create its
+// database without the source tree.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS